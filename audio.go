@@ -0,0 +1,80 @@
+package burner
+
+import (
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/ffprobe"
+	"strconv"
+)
+
+// applyAudio sets the audio encoding of conf on the Transcoder, the
+// options which are not set keep the preset of the mode.
+func applyAudio(t *ffmpeg.Transcoder, conf AudioConf) {
+	if conf.Codec == ffmpeg.AudioCodecCopy {
+		t.AudioCopy()
+		return
+	}
+	if conf.Codec != "" {
+		t.AudioCodec(conf.Codec)
+	}
+	if conf.Bitrate != 0 {
+		t.AudioBitrate(conf.Bitrate.String())
+	}
+	if conf.Channels > 0 {
+		t.AudioChannels(strconv.Itoa(conf.Channels))
+	}
+	if conf.SampleRate > 0 {
+		t.AudioSampleRate(strconv.Itoa(conf.SampleRate))
+	}
+}
+
+// probedCodecs are the codec names reported by ffprobe for the encoders.
+var probedCodecs = map[string]string{
+	ffmpeg.AudioCodecAAC:  "aac",
+	ffmpeg.AudioCodecOpus: "opus",
+	ffmpeg.AudioCodecAC3:  "ac3",
+}
+
+// compatibleAudio reports whether the audio streams are already encoded
+// as the output of conf, so they can be copied. The streams have to be
+// of the codec and fit into the channels, stereo when they are not set.
+func compatibleAudio(streams []ffprobe.Stream, conf AudioConf) bool {
+	codec := conf.Codec
+	if codec == "" {
+		codec = ffmpeg.AudioCodecAAC
+	}
+	channels := conf.Channels
+	if channels == 0 {
+		channels = 2
+	}
+	if len(streams) == 0 || conf.SampleRate > 0 || conf.Downmix != "" {
+		return false
+	}
+	for _, s := range streams {
+		if s.CodecName != probedCodecs[codec] || s.Channels == 0 || s.Channels > channels {
+			return false
+		}
+	}
+	return true
+}
+
+// copyAudio reports whether the probed audio is copied into the output,
+// the audio of filtered or joined outputs is always encoded.
+func copyAudio(f ffmpeg.Filter, streams []ffprobe.Stream, conf Config) bool {
+	if !conf.Audio.CopyCompatible || conf.FFprobePath == "" || conf.Audio.Codec == ffmpeg.AudioCodecCopy {
+		return false
+	}
+	if f.HasBumpers() || len(f.AudioFilters()) > 0 {
+		return false
+	}
+	// The transcode mode keeps every audio stream, the others the one
+	// selected by ffmpeg
+	audio := ffprobe.OfType(streams, "audio")
+	if conf.Mode != ModeTranscode {
+		s, ok := defaultAudio(streams)
+		if !ok {
+			return false
+		}
+		audio = []ffprobe.Stream{s}
+	}
+	return compatibleAudio(audio, conf.Audio)
+}
@@ -0,0 +1,36 @@
+package burner
+
+import (
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/ffprobe"
+	"testing"
+)
+
+func TestCompatibleAudio(t *testing.T) {
+	stereo := ffprobe.Stream{CodecType: "audio", CodecName: "aac", Channels: 2}
+	surround := ffprobe.Stream{CodecType: "audio", CodecName: "aac", Channels: 6}
+	opus := ffprobe.Stream{CodecType: "audio", CodecName: "opus", Channels: 2}
+	tests := []struct {
+		name    string
+		streams []ffprobe.Stream
+		conf    AudioConf
+		want    bool
+	}{
+		{name: "aac stereo", streams: []ffprobe.Stream{stereo}, want: true},
+		{name: "aac surround", streams: []ffprobe.Stream{surround}},
+		{name: "aac surround kept", streams: []ffprobe.Stream{surround}, conf: AudioConf{Channels: 6}, want: true},
+		{name: "other codec", streams: []ffprobe.Stream{opus}},
+		{name: "opus output", streams: []ffprobe.Stream{opus}, conf: AudioConf{Codec: ffmpeg.AudioCodecOpus}, want: true},
+		{name: "every stream", streams: []ffprobe.Stream{stereo, opus}},
+		{name: "downmix", streams: []ffprobe.Stream{stereo}, conf: AudioConf{Downmix: "mono|c0=FL"}},
+		{name: "sample rate", streams: []ffprobe.Stream{stereo}, conf: AudioConf{SampleRate: 44100}},
+		{name: "no audio"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compatibleAudio(tt.streams, tt.conf); got != tt.want {
+				t.Errorf("compatibleAudio() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
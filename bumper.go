@@ -0,0 +1,80 @@
+package burner
+
+import (
+	"fmt"
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/ffprobe"
+	"time"
+)
+
+// BumperConf holds the clips joined with every output of the mp4 and
+// fragmented mp4 modes, e.g. the intro of the group or a sponsor card.
+type BumperConf struct {
+	Intro []string
+	Outro []string
+}
+
+// useBumpers reports whether the outputs of conf are joined with bumpers.
+func useBumpers(conf Config) bool {
+	return (conf.Mode == ModeMP4 || conf.Mode == ModeFragmentedMP4) &&
+		len(conf.Bumpers.Intro)+len(conf.Bumpers.Outro) > 0
+}
+
+// probeBumpers builds the bumpers of the clips with their duration.
+// Without ffprobe every clip is expected to have an audio stream.
+func probeBumpers(paths []string, conf Config) ([]ffmpeg.Bumper, error) {
+	var bumpers []ffmpeg.Bumper
+	for _, p := range paths {
+		b := ffmpeg.Bumper{Path: p}
+		if conf.FFprobePath != "" {
+			streams, err := ffprobe.Streams(conf.FFprobePath, p)
+			if err != nil {
+				return nil, fmt.Errorf("was not able to probe bumper %s: %w", p, err)
+			}
+			duration, err := ffprobe.Duration(conf.FFprobePath, p)
+			if err != nil {
+				return nil, fmt.Errorf("was not able to probe bumper %s: %w", p, err)
+			}
+			b.Silent = len(ffprobe.OfType(streams, "audio")) == 0
+			b.Duration = time.Duration(duration * float64(time.Second))
+		}
+		bumpers = append(bumpers, b)
+	}
+	return bumpers, nil
+}
+
+// planBumpers sets the probed bumpers of the filter.
+func planBumpers(f ffmpeg.Filter, conf Config) (ffmpeg.Filter, error) {
+	var err error
+	if f.Intro, err = probeBumpers(conf.Bumpers.Intro, conf); err != nil {
+		return f, err
+	}
+	if f.Outro, err = probeBumpers(conf.Bumpers.Outro, conf); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+// bumperLength is the length of the bumpers of the filter in seconds.
+func bumperLength(f ffmpeg.Filter) float64 {
+	var d time.Duration
+	for _, b := range f.Intro {
+		d += b.Duration
+	}
+	for _, b := range f.Outro {
+		d += b.Duration
+	}
+	return d.Seconds()
+}
+
+// bumperAudio is the audio stream joined with the bumpers, the default
+// of the probed audio streams.
+func bumperAudio(streams []ffprobe.Stream, conf Config) string {
+	if conf.FFprobePath == "" {
+		return "0:a:0"
+	}
+	if s, ok := defaultAudio(streams); ok {
+		return fmt.Sprintf("0:%d", s.Index)
+	}
+	return ""
+}
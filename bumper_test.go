@@ -0,0 +1,55 @@
+package burner
+
+import (
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"testing"
+	"time"
+)
+
+func TestUseBumpers(t *testing.T) {
+	clips := BumperConf{Intro: []string{"/in/intro.mp4"}}
+	tests := []struct {
+		name string
+		conf Config
+		want bool
+	}{
+		{name: "mp4", conf: Config{Mode: ModeMP4, Bumpers: clips}, want: true},
+		{name: "fragmented mp4", conf: Config{Mode: ModeFragmentedMP4, Bumpers: BumperConf{Outro: []string{"/in/card.mp4"}}}, want: true},
+		{name: "sample", conf: Config{Mode: ModeSampleMP4, Bumpers: clips}},
+		{name: "transcode", conf: Config{Mode: ModeTranscode, Bumpers: clips}},
+		{name: "no clips", conf: Config{Mode: ModeMP4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := useBumpers(tt.conf); got != tt.want {
+				t.Errorf("useBumpers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBumperLength(t *testing.T) {
+	tests := []struct {
+		name string
+		f    ffmpeg.Filter
+		want float64
+	}{
+		{name: "no bumpers"},
+		{
+			name: "intro and outro",
+			f: ffmpeg.Filter{
+				Intro: []ffmpeg.Bumper{{Path: "/in/intro.mp4", Duration: 4500 * time.Millisecond}},
+				Outro: []ffmpeg.Bumper{{Path: "/in/card.mp4", Silent: true, Duration: 5 * time.Second}},
+			},
+			want: 9.5,
+		},
+		{name: "not probed", f: ffmpeg.Filter{Intro: []ffmpeg.Bumper{{Path: "/in/intro.mp4"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bumperLength(tt.f); got != tt.want {
+				t.Errorf("bumperLength() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
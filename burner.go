@@ -3,23 +3,29 @@ package burner
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"github.com/shiroi-usagi/burner/commandline"
 	"github.com/shiroi-usagi/burner/ffmpeg"
 	"github.com/shiroi-usagi/burner/ffprobe"
 	"github.com/shiroi-usagi/burner/filepathutil"
+	"github.com/shiroi-usagi/burner/jobqueue"
+	"github.com/shiroi-usagi/burner/report"
 	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 var (
-	DefaultHeight  = 720
-	DefaultBitrate = "1371k"
+	DefaultHeight       = 720
+	DefaultBitrate      = 1371 * ffmpeg.Kilobit
+	DefaultAudioBitrate = 128 * ffmpeg.Kilobit
 
 	// supportedInputExt filters the files from the input directory
 	supportedInputExt = []string{".mkv", ".mp4", ".avs"}
@@ -27,9 +33,59 @@ var (
 
 type VideoConf struct {
 	Height      int
-	Bitrate     string
+	Bitrate     ffmpeg.Bitrate
 	Upscaling   bool
 	KeepBitrate bool
+	// SourceRatio caps the bitrate at this ratio of the video bitrate
+	// of the source, zero is treated as one.
+	SourceRatio float64
+	Search      SearchConf
+	// AutoCrop detects the black bars of the inputs and crops them.
+	AutoCrop bool
+	// Crop is applied on the inputs instead of the detected crop.
+	Crop ffmpeg.Crop
+	// Deinterlace deinterlaces the inputs with the Deinterlacer,
+	// bwdif when it is not set.
+	Deinterlace  Switch
+	Deinterlacer ffmpeg.Deinterlacer
+	// InverseTelecine restores the progressive frames of telecined inputs.
+	InverseTelecine Switch
+	// RateMode decides the frame rate of the outputs from the FrameRate.
+	RateMode  RateMode
+	FrameRate ffmpeg.FrameRate
+	// SubtitleAfterScale renders the subtitle on the scaled frames.
+	SubtitleAfterScale bool
+	// Tonemap maps HDR inputs to SDR with the TonemapAlgorithm, hable
+	// when it is not set. TonemapPeak overrides the peak of the signal.
+	// The auto setting only converts the primaries of SDR BT.2020 inputs.
+	Tonemap          Switch
+	TonemapAlgorithm ffmpeg.TonemapAlgorithm
+	TonemapPeak      float64
+	// Watermark is drawn over the scaled frames of the outputs.
+	Watermark ffmpeg.Watermark
+	// CopyCompatible copies the video of the transcode mode which already
+	// meets the target, the file is remuxed instead of encoded.
+	CopyCompatible bool
+	// Copy copies the video without encoding, it is set by the plan.
+	Copy bool
+}
+
+type AudioConf struct {
+	Bitrate ffmpeg.Bitrate
+	// Loudnorm normalizes the loudness of the audio to the Loudness.
+	Loudnorm bool
+	Loudness ffmpeg.LoudnessTarget
+
+	// Codec, Channels and SampleRate replace the preset of the mode when
+	// they are set. The copy codec keeps the audio of the inputs.
+	Codec      string
+	Channels   int
+	SampleRate int
+	// Downmix is the matrix of the pan filter applied to the audio.
+	Downmix string
+	// CopyCompatible copies the audio which is already encoded as the
+	// output, e.g. AAC stereo, instead of encoding it again.
+	CopyCompatible bool
 }
 
 type Config struct {
@@ -43,10 +99,65 @@ type Config struct {
 	FFprobePath string
 
 	Video VideoConf
+	Audio AudioConf
+
+	Bumpers BumperConf
+	// Subtitles decides whether the subtitle of the hardsub modes is
+	// burned, muxed or both.
+	Subtitles SubtitleMode
 
 	IgnoreFontError bool
+
+	// QueueFile is the journal of the persistent job queue,
+	// empty keeps the queue in memory.
+	QueueFile string
+	Retry     jobqueue.RetryPolicy
+	// RetryFailed returns the jobs which failed in a previous run to
+	// pending, the finished jobs are never encoded again.
+	RetryFailed bool
+
+	FontRetry FontRetryConf
+
+	// Rules are applied on the output of ffmpeg before the built-in handlers.
+	Rules commandline.RuleSet
+
+	// ReportFile is the path of the JSON batch report, empty disables it.
+	ReportFile string
+	// JUnitFile is the path of the JUnit XML batch report, empty disables it.
+	JUnitFile string
+
+	// DryRun prints the plan of the batch without executing it, the
+	// inputs are only probed.
+	DryRun bool
+	// EmitScript is the path of a shell script or Makefile which is
+	// written instead of executing the batch.
+	EmitScript string
+
+	// TargetSize is the highest size of an output in bytes, the video
+	// bitrate is derived from it. Zero disables the target.
+	TargetSize int64
+
+	// Workers encode the jobs instead of this machine when not empty.
+	Workers []Worker
+
+	Chunks ChunkConf
+
+	Quality QualityConf
 }
 
+// FontRetryConf describes how a file stopped on a font error is retried.
+//
+// The fallback font is tried first, then the font checks are ignored.
+type FontRetryConf struct {
+	// FallbackFont replaces the fonts of the subtitle through force_style.
+	FallbackFont string
+	// IgnoreErrors retries the file with IgnoreFontError and flags the output as degraded.
+	IgnoreErrors bool
+}
+
+// errFontKilled is returned when ffmpeg was stopped on a font error.
+var errFontKilled = errors.New("ffmpeg was stopped on a font error")
+
 func Burn(conf Config) {
 	if _, err := os.Stat(conf.InputDir); err != nil {
 		log.Fatal("missing input directory")
@@ -55,17 +166,7 @@ func Burn(conf Config) {
 		log.Fatal("missing output directory")
 	}
 
-	var factory factoryFunc
-	switch conf.Mode {
-	case ModeSampleMP4:
-		factory = ffmpeg.NewSampleMp4Transcoder
-	case ModeFragmentedMP4:
-		factory = ffmpeg.NewFragmentedMp4Transcoder
-	case ModeMP4:
-		factory = ffmpeg.NewMp4Transcoder
-	case ModeTranscode:
-		factory = ffmpeg.NewTranscoder
-	default:
+	if factoryFor(conf.Mode) == nil {
 		log.Print("Was not able to detect mode")
 		return
 	}
@@ -74,26 +175,304 @@ func Burn(conf Config) {
 		log.Print(conf.FFmpegPath)
 	}
 
-	files := filepathutil.ListFilesWithExt(conf.InputDir, supportedInputExt...)
-	l := len(files)
+	open := jobqueue.Open
+	if conf.DryRun || conf.EmitScript != "" {
+		// Nothing is executed, the journal is left untouched
+		open = jobqueue.Load
+	}
+	q, err := open(conf.QueueFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer q.Close()
 
-	cmdOut := &modifiableOutput{Stdout: os.Stdout}
-	for i, file := range files {
-		log.Printf("[%03d/%03d] %s", i+1, l, filepath.Base(file))
-		if err := burn(cmdOut, file, factory, conf); err != nil {
+	for _, file := range filepathutil.ListFilesWithExt(conf.InputDir, supportedInputExt...) {
+		job, added, err := q.Enqueue(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		switch {
+		case added:
+		case job.Status == jobqueue.StatusDone:
+			log.Printf("%s is skipped, it was encoded in a previous run of the queue %s", filepath.Base(file), conf.QueueFile)
+		case job.Status == jobqueue.StatusFailed && !conf.RetryFailed:
+			log.Printf("%s is skipped, it failed in a previous run, --retry-failed encodes it again", filepath.Base(file))
+		}
+	}
+	if conf.RetryFailed {
+		for _, job := range q.List() {
+			if job.Status != jobqueue.StatusFailed {
+				continue
+			}
+			if err := q.Retry(job.ID); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	var l int
+	for _, job := range q.List() {
+		if job.Status == jobqueue.StatusPending {
+			l++
+		}
+	}
+
+	if conf.DryRun || conf.EmitScript != "" {
+		var jobs []jobqueue.Job
+		for _, job := range q.List() {
+			if job.Status == jobqueue.StatusPending {
+				jobs = append(jobs, job)
+			}
+		}
+		if conf.DryRun {
+			dryRun(os.Stdout, jobs, conf)
+		}
+		if conf.EmitScript != "" {
+			if err := emitScript(conf.EmitScript, jobs, conf); err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("script was written to %s", conf.EmitScript)
+		}
+		return
+	}
+
+	b := &batch{
+		q:       q,
+		conf:    conf,
+		total:   l,
+		report:  report.Report{Version: GetVersionInfo().Version, Started: time.Now()},
+		entries: map[int]int{},
+		seen:    map[int]bool{},
+	}
+	if len(conf.Workers) == 0 {
+		b.run(&modifiableOutput{Stdout: os.Stdout}, burnJob)
+	} else {
+		var wg sync.WaitGroup
+		for _, w := range conf.Workers {
+			wg.Add(1)
+			go func(w Worker) {
+				defer wg.Done()
+				cmdOut := &modifiableOutput{Stdout: &prefixWriter{Writer: os.Stdout, prefix: "[" + w.Name + "] "}}
+				b.run(cmdOut, func(cmdOut *modifiableOutput, job jobqueue.Job, conf Config, e *report.Entry) error {
+					return burnRemoteJob(cmdOut, w, job, conf, e)
+				})
+			}(w)
+		}
+		wg.Wait()
+	}
+
+	printWarnings(b.report)
+}
+
+// batch is the state of the jobs processed by Burn, it is shared
+// between the workers.
+type batch struct {
+	q     *jobqueue.Queue
+	conf  Config
+	total int
+
+	mu      sync.Mutex
+	report  report.Report
+	entries map[int]int
+	seen    map[int]bool
+}
+
+// run processes the jobs of the queue with burn until none is left.
+func (b *batch) run(cmdOut *modifiableOutput, burn func(cmdOut *modifiableOutput, job jobqueue.Job, conf Config, e *report.Entry) error) {
+	for {
+		job, wait, ok, err := b.q.Next()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !ok {
+			if wait < 0 {
+				return
+			}
+			log.Printf("waiting %s for the next retry", wait.Round(time.Second))
+			time.Sleep(wait)
+			continue
+		}
+		b.mu.Lock()
+		b.seen[job.ID] = true
+		i := len(b.seen)
+		b.mu.Unlock()
+		if job.Attempts > 1 {
+			log.Printf("[%03d/%03d] %s (attempt %d)", i, b.total, filepath.Base(job.Input), job.Attempts)
+		} else {
+			log.Printf("[%03d/%03d] %s", i, b.total, filepath.Base(job.Input))
+		}
+		jobConf := withSettings(b.conf, job.Settings)
+		e := report.Entry{Input: job.Input, Mode: jobConf.Mode.Name()}
+		err = burn(cmdOut, job, jobConf, &e)
+		if err != nil {
 			log.Print(err)
+			e.Error = err.Error()
+			if err := b.q.Fail(job.ID, err, b.conf.Retry); err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			if e.Remuxed {
+				log.Printf("%s was remuxed, the video was not encoded", filepath.Base(job.Input))
+			}
+			if e.Degraded {
+				log.Printf("%s was encoded with font errors ignored, the output is degraded", filepath.Base(job.Input))
+			}
+			if err := b.q.Done(job.ID); err != nil {
+				log.Fatal(err)
+			}
+		}
+		b.record(job, e)
+	}
+}
+
+// record adds the entry of the job to the report and writes it.
+func (b *batch) record(job jobqueue.Job, e report.Entry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// A retried job replaces the entry of its previous attempt
+	if i, ok := b.entries[job.ID]; ok {
+		b.report.Entries[i] = e
+	} else {
+		b.entries[job.ID] = len(b.report.Entries)
+		b.report.Entries = append(b.report.Entries, e)
+	}
+	b.report.Finished = time.Now()
+	writeReport(b.report, b.conf)
+}
+
+// printWarnings prints the summary of the collected
+// ffmpeg warnings for each file of the report.
+func printWarnings(r report.Report) {
+	for _, e := range r.Entries {
+		if len(e.FFmpegWarnings) == 0 {
 			continue
 		}
+		log.Printf("warnings of %s:", filepath.Base(e.Input))
+		for _, w := range e.FFmpegWarnings {
+			if w.First != "" {
+				log.Printf("  %dx [%s] %s (first at %s)", w.Count, w.Kind, w.Message, w.First)
+			} else {
+				log.Printf("  %dx [%s] %s", w.Count, w.Kind, w.Message)
+			}
+		}
+	}
+}
+
+// burnJob encodes the job on this machine and measures
+// the wall time of the encoding into e.
+func burnJob(cmdOut *modifiableOutput, job jobqueue.Job, conf Config, e *report.Entry) error {
+	start := time.Now()
+	defer func() {
+		e.WallTime = time.Since(start).Seconds()
+	}()
+	factory := factoryFor(conf.Mode)
+	if factory == nil {
+		return errors.New("unknown mode")
+	}
+	return burn(cmdOut, job.Input, factory, conf, e)
+}
+
+// writeReport writes the batch report files of the configuration.
+//
+// The report is rewritten after every job, so it is available
+// even when the batch is interrupted.
+func writeReport(r report.Report, conf Config) {
+	if conf.ReportFile != "" {
+		if err := report.WriteFile(conf.ReportFile, r.WriteJSON); err != nil {
+			log.Printf("was not able to write report: %s", err)
+		}
 	}
+	if conf.JUnitFile != "" {
+		if err := report.WriteFile(conf.JUnitFile, r.WriteJUnit); err != nil {
+			log.Printf("was not able to write JUnit report: %s", err)
+		}
+	}
+}
+
+// factoryFor returns the Transcoder factory of the mode,
+// or nil when the mode is unknown.
+func factoryFor(m Mode) factoryFunc {
+	switch m {
+	case ModeSampleMP4:
+		return ffmpeg.NewSampleMp4Transcoder
+	case ModeFragmentedMP4:
+		return ffmpeg.NewFragmentedMp4Transcoder
+	case ModeMP4:
+		return ffmpeg.NewMp4Transcoder
+	case ModeTranscode:
+		return ffmpeg.NewTranscoder
+	}
+	return nil
+}
+
+// withSettings applies the per-job overrides on the batch configuration.
+func withSettings(conf Config, s jobqueue.Settings) Config {
+	if s.Mode != "" {
+		conf.Mode = StringToMode(s.Mode)
+	}
+	if s.Bitrate != "" {
+		// The bitrate was validated when the job was added
+		if b, err := ffmpeg.ParseBitrate(s.Bitrate); err == nil {
+			conf.Video.Bitrate = b
+		}
+	}
+	if s.Height != 0 {
+		conf.Video.Height = s.Height
+	}
+	if s.Subtitles != "" {
+		// The subtitles were validated when the job was added
+		conf.Subtitles = SubtitleMode(s.Subtitles)
+	}
+	switch s.Crop {
+	case "":
+	case CropAuto:
+		conf.Video.AutoCrop = true
+		conf.Video.Crop = ffmpeg.Crop{}
+	case CropNone:
+		conf.Video.AutoCrop = false
+		conf.Video.Crop = ffmpeg.Crop{}
+	default:
+		// The crop was validated when the job was added
+		if c, err := ffmpeg.ParseCrop(s.Crop); err == nil {
+			conf.Video.Crop = c
+		}
+	}
+	return conf
+}
+
+type factoryFunc func(executable string, input string, outDir string, bitrate ffmpeg.Bitrate, f ffmpeg.Filter) *ffmpeg.Transcoder
+
+// newTranscoder builds the Transcoder of the factory with
+// the bitrates of the configuration. A zero audio bitrate
+// keeps the bitrate of the preset.
+func newTranscoder(factory factoryFunc, executable, input, outDir string, f ffmpeg.Filter, conf Config) *ffmpeg.Transcoder {
+	t := factory(executable, input, outDir, conf.Video.Bitrate, f)
+	applyAudio(t, conf.Audio)
+	if conf.Video.Copy {
+		t.VideoCopy()
+	}
+	return t
 }
 
-type factoryFunc func(executable string, input string, outDir string, bitrate string, f ffmpeg.Filter) *ffmpeg.Transcoder
+// burn encodes the file with the Transcoder of the factory
+// and records the outcome into e.
+//
+// Files stopped on a font error are retried as described by
+// conf.FontRetry, e.Degraded reports whether the font checks were
+// ignored for the output.
+func burn(cmdOut *modifiableOutput, file string, factory factoryFunc, conf Config, e *report.Entry) error {
+	conf, f, _, err := plan(file, conf, e)
+	if err != nil {
+		return err
+	}
+	conf, err = searchBitrate(cmdOut, file, f, conf, e)
+	if err != nil {
+		return err
+	}
 
-func burn(cmdOut *modifiableOutput, file string, factory factoryFunc, conf Config) error {
 	// Avoid dealing with escaping characters in complex filter
-	slink := filepath.Join(conf.OutputDir, "tmp"+filepath.Ext(file))
+	slink := tmpLink(file, conf)
 	_ = os.Remove(slink)
-	err := os.Link(file, slink)
+	err = os.Link(file, slink)
 	if err != nil {
 		return err
 	}
@@ -101,27 +480,250 @@ func burn(cmdOut *modifiableOutput, file string, factory factoryFunc, conf Confi
 		_ = os.Remove(slink)
 	}()
 
+	conf, err = withTargetSize(conf, e, func(conf Config) error {
+		return withFontRetry(f, conf, e, func(f ffmpeg.Filter, conf Config) error {
+			if useChunks(conf, e.Duration) {
+				return encodeChunked(cmdOut, file, f, conf, e)
+			}
+			t := newTranscoder(factory, conf.FFmpegPath, file, conf.OutputDir, f, conf)
+			if err := encode(cmdOut, t, conf, e); err != nil {
+				return err
+			}
+			return writeRenditions(cmdOut, file, t, f, conf, e)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return measureQuality(file, factory(conf.FFmpegPath, file, conf.OutputDir, conf.Video.Bitrate, f), f, conf, e)
+}
+
+// withFontRetry runs encode with the filter, files stopped on a font
+// error are encoded again as described by conf.FontRetry.
+func withFontRetry(f ffmpeg.Filter, conf Config, e *report.Entry, encode func(f ffmpeg.Filter, conf Config) error) error {
+	err := encode(f, conf)
+	if errors.Is(err, errFontKilled) && conf.FontRetry.FallbackFont != "" {
+		log.Printf("retrying with `%s` font", conf.FontRetry.FallbackFont)
+		f.ForceStyle = "FontName=" + conf.FontRetry.FallbackFont
+		err = encode(f, conf)
+	}
+	if errors.Is(err, errFontKilled) && conf.FontRetry.IgnoreErrors {
+		log.Print("retrying with font errors ignored")
+		conf.IgnoreFontError = true
+		e.Degraded = true
+		err = encode(f, conf)
+	}
+	return err
+}
+
+// tmpLink is the path of the hard link to file which is used
+// as the source of the subtitle.
+func tmpLink(file string, conf Config) string {
+	return filepath.Join(conf.OutputDir, "tmp"+filepath.Ext(file))
+}
+
+// plan adjusts the configuration to file and builds the filter
+// of the encode, the probed streams of file are returned for the
+// callers. The decisions are recorded into e.
+//
+// The dry run only probes the input, the crop, interlace and loudness
+// analyses of ffmpeg are detected at encode.
+func plan(file string, conf Config, e *report.Entry) (Config, ffmpeg.Filter, []ffprobe.Stream, error) {
 	// For YUV 4:2:0 chroma subsampled outputs width and height has to be divisible by 2
-	f := ffmpeg.Filter{Subtitle: slink, Width: -2, Height: conf.Video.Height, Upscaling: conf.Video.Upscaling}
+	f := ffmpeg.Filter{
+		Subtitle:           tmpLink(file, conf),
+		SubtitleAfterScale: conf.Video.SubtitleAfterScale,
+		Width:              -2,
+		Height:             conf.Video.Height,
+		Upscaling:          conf.Video.Upscaling,
+		Crop:               conf.Video.Crop,
+		Watermark:          conf.Video.Watermark,
+		Downmix:            conf.Audio.Downmix,
+	}
 
-	if !conf.Video.KeepBitrate && conf.FFprobePath != "" {
+	var idet ffmpeg.IdetStats
+	var hdr, wide, pulldown bool
+	var rate, average ffmpeg.FrameRate
+	var source ffmpeg.Bitrate
+	// The streams are probed once, they are shared by the decisions
+	var streams []ffprobe.Stream
+	if useBumpers(conf) {
+		var err error
+		if f, err = planBumpers(f, conf); err != nil {
+			return conf, f, streams, err
+		}
+	}
+
+	if conf.FFprobePath != "" {
 		duration, err := ffprobe.Duration(conf.FFprobePath, file)
-		if err != nil {
-			return err
+		if err != nil && !conf.Video.KeepBitrate {
+			return conf, f, streams, err
+		}
+		e.Duration = duration
+
+		if streams, err = ffprobe.Streams(conf.FFprobePath, file); err != nil {
+			if needsStreams(conf) {
+				return conf, f, streams, err
+			}
+			log.Printf("was not able to probe the streams, their detections are skipped: %s", err)
 		}
 
-		expectedSize := calcExpectedSize(duration, ffmpeg.BitrateToKilobit(conf.Video.Bitrate))
-		stat, _ := os.Stat(file)
-		size := float64(stat.Size())
-		if size < expectedSize {
-			kilobit := (size * 8 / 1024 / duration) - 128
-			conf.Video.Bitrate = ffmpeg.KilobitToBitrate(int64(kilobit))
-			log.Printf("bitrate was modified to %s", conf.Video.Bitrate)
+		if detectsCrop(f, conf, duration) && !conf.DryRun {
+			crop, err := detectCrop(file, duration, streams, conf)
+			if err != nil {
+				return conf, f, streams, err
+			}
+			if crop.IsZero() {
+				log.Print("no black bars were detected")
+			} else {
+				log.Printf("crop was set to %s", crop)
+			}
+			f.Crop = crop
 		}
+
+		if detectsInterlace(conf, duration) && !conf.DryRun {
+			stats, err := detectInterlace(file, duration, conf)
+			if err != nil {
+				return conf, f, streams, err
+			}
+			log.Printf("%.0f%% of the frames are interlaced", stats.Interlaced()*100)
+			idet = stats
+		}
+
+		rate, average, pulldown = probeFrameRate(streams)
+		if conf.Video.Tonemap == SwitchAuto {
+			hdr, wide = detectColor(streams)
+		}
+
+		if conf.TargetSize > 0 && duration > 0 {
+			length := duration
+			if factory := factoryFor(conf.Mode); factory != nil {
+				// Samples only hold a part of the input
+				if _, l := factory(conf.FFmpegPath, file, conf.OutputDir, conf.Video.Bitrate, f).Window(); l > 0 && l.Seconds() < length {
+					length = l.Seconds()
+				}
+			}
+			// The bumpers are joined with the output
+			length += bumperLength(f)
+			bitrate := targetBitrate(conf.TargetSize, length, conf.Audio.Bitrate)
+			if bitrate == 0 {
+				return conf, f, streams, fmt.Errorf("target size of %d bytes is too small for %.0f seconds", conf.TargetSize, length)
+			}
+			conf.Video.Bitrate = bitrate
+			log.Printf("bitrate was set to %s for the target size", conf.Video.Bitrate)
+		}
+
+		if !conf.Video.KeepBitrate || conf.Video.CopyCompatible {
+			source = sourceBitrate(file, duration, streams, conf)
+			if source > 0 {
+				e.SourceBitrate = source.String()
+			}
+		}
+		if !conf.Video.KeepBitrate {
+			ratio := conf.Video.SourceRatio
+			if ratio <= 0 {
+				ratio = 1
+			}
+			if limit := ffmpeg.Bitrate(float64(source) * ratio).Truncate(ffmpeg.Kilobit); limit > 0 && limit < conf.Video.Bitrate {
+				conf.Video.Bitrate = limit
+				e.BitrateModified = true
+				log.Printf("bitrate was modified to %s, the video of the source is %s", conf.Video.Bitrate, source)
+			}
+		}
+	}
+	f.Deinterlacer, f.InverseTelecine = interlaceStages(conf.Video, idet)
+	if f.InverseTelecine {
+		rate, average = telecineRates(rate, average, pulldown)
+	}
+	f.FrameRate, f.ConstantRate, f.VariableRate = rateStages(conf.Video, rate, average)
+	switch {
+	case f.ConstantRate:
+		e.FrameRate = f.FrameRate.String()
+		log.Printf("frame rate was set to %s", f.FrameRate)
+	case f.VariableRate:
+		e.FrameRate = "variable"
+		log.Print("the input has a variable frame rate, its timestamps are kept")
+	}
+	switch f.Tonemap = tonemapFor(conf.Video, hdr, wide); {
+	case f.Tonemap.Gamut:
+		e.Tonemap = "gamut"
+		log.Print("the BT.2020 primaries of the input are converted to BT.709")
+	case !f.Tonemap.IsZero():
+		e.Tonemap = string(f.Tonemap.Algorithm)
+		log.Printf("tonemap was set to %s", e.Tonemap)
+	}
+	if useBumpers(conf) {
+		f.AudioInput = bumperAudio(streams, conf)
+	}
+	if !conf.Subtitles.Burns() {
+		f.Subtitle = ""
+	}
+	if useSoftsub(conf) {
+		f = planSoftsub(f, streams, conf, e)
+	}
+	if conf.Audio.Loudnorm && !measuresLoudness(conf) {
+		// The mode of the job keeps every audio stream
+		log.Print("the transcode mode keeps every audio stream, their loudness is not normalized")
+	}
+	if measuresLoudness(conf) && !conf.DryRun {
+		var err error
+		if f, err = planLoudnorm(file, f, streams, conf, e); err != nil {
+			return conf, f, streams, err
+		}
+	}
+	if copyAudio(f, streams, conf) {
+		conf.Audio.Codec = ffmpeg.AudioCodecCopy
+		log.Print("the audio is already compatible, it is copied")
+	}
+	e.AudioCodec = conf.Audio.Codec
+	if conf.Video.Copy = copyVideo(f, streams, source, conf); conf.Video.Copy {
+		e.Remuxed = true
+		log.Print("the video already meets the target, the file is remuxed")
+	}
+	switch {
+	case f.InverseTelecine:
+		e.Deinterlace = "ivtc"
+	case f.Deinterlacer != ffmpeg.DeinterlacerNone:
+		e.Deinterlace = string(f.Deinterlacer)
 	}
 
-	t := factory(conf.FFmpegPath, file, conf.OutputDir, conf.Video.Bitrate, f)
+	e.Bitrate = conf.Video.Bitrate.String()
+	if !f.Crop.IsZero() {
+		e.Crop = f.Crop.String()
+	}
+	return conf, f, streams, nil
+}
+
+// detectsCrop reports whether the plan detects the black bars of the
+// input of the given duration.
+func detectsCrop(f ffmpeg.Filter, conf Config, duration float64) bool {
+	return conf.Video.AutoCrop && f.Crop.IsZero() && duration > 0
+}
+
+// detectsInterlace reports whether the plan detects the interlaced
+// frames of the input of the given duration.
+func detectsInterlace(conf Config, duration float64) bool {
+	return (conf.Video.Deinterlace == SwitchAuto || conf.Video.InverseTelecine == SwitchAuto) && duration > 0
+}
+
+// needsStreams reports whether the plan can not be made without the
+// probed streams of the input, e.g. to select the muxed subtitles. The
+// detections of the optional settings, e.g. of HDR, are skipped instead.
+func needsStreams(conf Config) bool {
+	return useSoftsub(conf) || useBumpers(conf) || measuresLoudness(conf) ||
+		conf.Audio.CopyCompatible || conf.Video.CopyCompatible ||
+		(conf.Video.RateMode == RateCFR && conf.Video.FrameRate.IsZero())
+}
+
+// measuresLoudness reports whether the plan measures the loudness of
+// the input, the transcode mode keeps every audio stream.
+func measuresLoudness(conf Config) bool {
+	return conf.Audio.Loudnorm && conf.Mode != ModeTranscode
+}
 
+// encode runs both passes of the Transcoder and records
+// the output into e.
+func encode(cmdOut *modifiableOutput, t *ffmpeg.Transcoder, conf Config, e *report.Entry) error {
 	if err := os.MkdirAll(t.OutDir(), 0755); err != nil {
 		return err
 	}
@@ -131,25 +733,102 @@ func burn(cmdOut *modifiableOutput, file string, factory factoryFunc, conf Confi
 		_ = os.Remove(filepath.Join(t.OutDir(), "ffmpeg2pass-0.log"))
 		_ = os.Remove(filepath.Join(t.OutDir(), "ffmpeg2pass-0.log.mbtree"))
 	}()
-	if err := runCommand(cmdOut, t.FirstPass(), conf); err != nil {
+	if err := runPasses(cmdOut, t, conf, e, nil); err != nil {
 		return err
 	}
 
-	if err := runCommand(cmdOut, t.SecondPass(), conf); err != nil {
+	e.Output = t.Output()
+	e.OutputSize = outputSize(t)
+	return nil
+}
+
+// runPasses runs both passes of the Transcoder and records their timing
+// and warnings into e. The commands are passed through wrap when it is
+// not nil, e.g. to run them on a worker. A remux runs the single pass,
+// timed as the second pass.
+func runPasses(cmdOut *modifiableOutput, t *ffmpeg.Transcoder, conf Config, e *report.Entry, wrap func(*exec.Cmd) *exec.Cmd) error {
+	if wrap == nil {
+		wrap = func(cmd *exec.Cmd) *exec.Cmd { return cmd }
+	}
+	out := &warningRecorder{Writer: cmdOut}
+	defer func() {
+		e.Warnings = append(e.Warnings, out.warnings...)
+	}()
+	first, second := ffmpeg.NewWarningCollector(), ffmpeg.NewWarningCollector()
+	defer func() {
+		e.FFmpegWarnings = ffmpeg.MergeWarnings(first.Warnings(), second.Warnings())
+	}()
+
+	if t.Remux() {
+		start := time.Now()
+		if err := runCommand(out, wrap(t.SinglePass()), second, conf); err != nil {
+			return err
+		}
+		e.SecondPassTime = time.Since(start).Seconds()
+		return nil
+	}
+
+	start := time.Now()
+	if err := runCommand(out, wrap(t.FirstPass()), first, conf); err != nil {
 		return err
 	}
+	e.FirstPassTime = time.Since(start).Seconds()
 
+	start = time.Now()
+	if err := runCommand(out, wrap(t.SecondPass()), second, conf); err != nil {
+		return err
+	}
+	e.SecondPassTime = time.Since(start).Seconds()
 	return nil
 }
 
-func calcExpectedSize(duration float64, bitrate int64) float64 {
-	return (float64(bitrate) + 128) * duration / 8 * 1024
+// outputSize is the size of the output of t in bytes. Playlists
+// are counted together with their segments.
+func outputSize(t *ffmpeg.Transcoder) int64 {
+	if filepath.Ext(t.Output()) != ".m3u8" {
+		stat, err := os.Stat(t.Output())
+		if err != nil {
+			return 0
+		}
+		return stat.Size()
+	}
+	var size int64
+	_ = filepath.Walk(t.OutDir(), func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// sourceBitrate estimates the video bitrate of the file from its probed
+// streams. When it can not be probed, the size of the file is used with
+// the audio bitrate of the configuration subtracted.
+func sourceBitrate(file string, duration float64, streams []ffprobe.Stream, conf Config) ffmpeg.Bitrate {
+	b, err := ffprobe.VideoBitrate(conf.FFprobePath, file, streams, duration)
+	switch {
+	case err != nil:
+		log.Printf("was not able to probe the video bitrate, estimating from the file size: %v", err)
+	case b > 0:
+		return ffmpeg.Bitrate(b)
+	default:
+		log.Print("ffprobe reported no video bitrate, estimating from the file size")
+	}
+	stat, err := os.Stat(file)
+	if err != nil || duration <= 0 {
+		return 0
+	}
+	return ffmpeg.Bitrate(float64(stat.Size())*8/duration) - conf.Audio.Bitrate
 }
 
 // runCommand runs the given command while writing the output to console.
 //
-// The verbose argument makes the output more talkative.
-func runCommand(out io.Writer, cmd *exec.Cmd, conf Config) error {
+// The verbose argument makes the output more talkative. The lines of
+// stderr are collected into warnings, only their first occurrence is
+// written. When a font check stops the command the returned error
+// wraps errFontKilled.
+func runCommand(out io.Writer, cmd *exec.Cmd, warnings *ffmpeg.WarningCollector, conf Config) error {
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
 		return err
@@ -159,12 +838,14 @@ func runCommand(out io.Writer, cmd *exec.Cmd, conf Config) error {
 		return err
 	}
 	if conf.Verbose {
-		fmt.Println(cmd)
+		fmt.Fprintln(out, cmd)
 	}
 	if err := cmd.Start(); err != nil {
 		return err
 	}
 
+	var fontKilled bool
+	counts := map[string]int{}
 	var wg sync.WaitGroup
 	wg.Add(2)
 	go func() {
@@ -177,13 +858,16 @@ func runCommand(out io.Writer, cmd *exec.Cmd, conf Config) error {
 			if strings.HasPrefix(line, "progress=") {
 				// stats_period flag not available in all versions
 				if i == 0 {
-					fmt.Println(sb.String())
+					fmt.Fprintln(out, sb.String())
 				}
 				i = (i + 1) % 4 // default stats_period is 0.5 seconds, we only need info every 2 seconds
 				sb.Reset()
 				// The last key of a sequence of progress information is always "progress".
 				continue
 			}
+			if strings.HasPrefix(line, "out_time=") {
+				warnings.SetPosition(strings.TrimPrefix(line, "out_time="))
+			}
 			if conf.Verbose {
 				sb.WriteString(line)
 				sb.WriteString(" ")
@@ -203,11 +887,13 @@ func runCommand(out io.Writer, cmd *exec.Cmd, conf Config) error {
 	go func() {
 		defer wg.Done()
 		s := bufio.NewScanner(stderr)
-		h := ffmpeg.Printer()
+		h := warnings.Handler(ffmpeg.Printer())
 		if !conf.IgnoreFontError {
 			h = ffmpeg.KillOnReplacedMissingFontLine(h)
 			h = ffmpeg.KillOnGlyphNotFoundLine(h)
+			h = recordSignal(h, &fontKilled)
 		}
+		h = conf.Rules.Handler(h, counts)
 		h = ffmpeg.KillOnNotOverwritingLine(h)
 		for s.Scan() {
 			line := s.Text()
@@ -215,13 +901,77 @@ func runCommand(out io.Writer, cmd *exec.Cmd, conf Config) error {
 		}
 	}()
 
+	// Wait closes the pipes, the output has to be read before
+	wg.Wait()
+	msgs := make([]string, 0, len(counts))
+	for msg := range counts {
+		msgs = append(msgs, msg)
+	}
+	sort.Strings(msgs)
+	for _, msg := range msgs {
+		fmt.Fprintf(out, "burner: %s (%d times)", msg, counts[msg])
+	}
 	if err := cmd.Wait(); err != nil {
+		if fontKilled {
+			return fmt.Errorf("%w: %v", errFontKilled, err)
+		}
 		return err
 	}
-	wg.Wait()
 	return nil
 }
 
+// prefixWriter is an io.Writer which prefixes every write,
+// it tells apart the output of the workers.
+type prefixWriter struct {
+	io.Writer
+	prefix string
+}
+
+func (w *prefixWriter) Write(p []byte) (n int, err error) {
+	// A single write keeps the prefix with the line of concurrent writers
+	if _, err := w.Writer.Write(append([]byte(w.prefix), p...)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// warningRecorder is an io.Writer which keeps the
+// messages written by the handlers of burner.
+type warningRecorder struct {
+	io.Writer
+	mu       sync.Mutex
+	warnings []string
+}
+
+func (w *warningRecorder) Write(p []byte) (n int, err error) {
+	if msg := string(p); strings.HasPrefix(msg, "burner: ") {
+		w.mu.Lock()
+		w.warnings = append(w.warnings, strings.TrimSpace(strings.TrimPrefix(msg, "burner: ")))
+		w.mu.Unlock()
+	}
+	return w.Writer.Write(p)
+}
+
+// signalRecorder is a commandline.Signaller which marks that
+// a signal was sent to the process.
+type signalRecorder struct {
+	commandline.Signaller
+	signalled *bool
+}
+
+func (s signalRecorder) Signal(sig os.Signal) error {
+	*s.signalled = true
+	return s.Signaller.Signal(sig)
+}
+
+// recordSignal sets signalled when the next handler sends a signal.
+func recordSignal(next commandline.Handler, signalled *bool) commandline.Handler {
+	return commandline.HandlerFunc(func(r commandline.Response, l string) {
+		r.Signaller = signalRecorder{Signaller: r.Signaller, signalled: signalled}
+		next.Handle(r, l)
+	})
+}
+
 // modifiableOutput is an io.Writer which can detect carriage return
 // and there for allow for a line to be modified.
 type modifiableOutput struct {
@@ -0,0 +1,73 @@
+package burner
+
+import (
+	"github.com/shiroi-usagi/burner/commandline"
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+type fakeSignaller struct {
+	lastSignal os.Signal
+}
+
+func (f *fakeSignaller) Signal(signal os.Signal) error {
+	f.lastSignal = signal
+	return nil
+}
+
+func TestRecordSignal(t *testing.T) {
+	tests := []struct {
+		name          string
+		commandline   string
+		wantSignalled bool
+	}{
+		{
+			name:          "glyph not found",
+			commandline:   `[Parsed_subtitles_0 @ anyhex] Glyph 0x266F not found, selecting one more font for (anystring, 0, 0)`,
+			wantSignalled: true,
+		},
+		{
+			name:        "safe string",
+			commandline: `any line`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signaller := fakeSignaller{}
+			var signalled bool
+			h := recordSignal(ffmpeg.KillOnGlyphNotFoundLine(ffmpeg.Printer()), &signalled)
+			h.Handle(commandline.Response{Signaller: &signaller, Stdout: ioutil.Discard}, tt.commandline)
+			if signalled != tt.wantSignalled {
+				t.Errorf("recordSignal() signalled = %v, want %v", signalled, tt.wantSignalled)
+			}
+			if tt.wantSignalled && signaller.lastSignal != os.Kill {
+				t.Errorf("recordSignal() should forward the signal")
+			}
+		})
+	}
+}
+
+func TestNeedsStreams(t *testing.T) {
+	tests := []struct {
+		name string
+		conf Config
+		want bool
+	}{
+		{name: "keep bitrate", conf: Config{Mode: ModeMP4, Video: VideoConf{KeepBitrate: true, Tonemap: SwitchAuto}}},
+		{name: "soft subtitles", conf: Config{Mode: ModeMP4, Subtitles: SubtitlesSoft}, want: true},
+		{name: "loudnorm", conf: Config{Mode: ModeMP4, Audio: AudioConf{Loudnorm: true}}, want: true},
+		{name: "loudnorm of transcode", conf: Config{Mode: ModeTranscode, Audio: AudioConf{Loudnorm: true}}},
+		{name: "copy compatible", conf: Config{Mode: ModeTranscode, Video: VideoConf{CopyCompatible: true}}, want: true},
+		{name: "cfr of the input rate", conf: Config{Mode: ModeMP4, Video: VideoConf{RateMode: RateCFR}}, want: true},
+		{name: "cfr of a rate", conf: Config{Mode: ModeMP4, Video: VideoConf{RateMode: RateCFR, FrameRate: ffmpeg.NewFrameRate(30, 1)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := needsStreams(tt.conf); got != tt.want {
+				t.Errorf("needsStreams() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
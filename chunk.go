@@ -0,0 +1,151 @@
+package burner
+
+import (
+	"fmt"
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/ffprobe"
+	"github.com/shiroi-usagi/burner/report"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChunkConf enables the parallel encoding of long files in MP4 mode.
+type ChunkConf struct {
+	// Count is the number of chunks encoded in parallel, chunking
+	// is disabled below two.
+	Count int
+	// MinDuration is the shortest input which is split into chunks.
+	MinDuration time.Duration
+}
+
+// chunk is a part of the input starting at a keyframe.
+type chunk struct {
+	start  time.Duration
+	length time.Duration
+}
+
+// useChunks reports whether the file of the given duration is
+// encoded in chunks. The bumpers are only joined by whole encodes.
+func useChunks(conf Config, duration float64) bool {
+	return conf.Chunks.Count > 1 && conf.Mode == ModeMP4 && conf.FFprobePath != "" && !useBumpers(conf) && !useSoftsub(conf) &&
+		duration > 0 && duration >= conf.Chunks.MinDuration.Seconds()
+}
+
+// splitChunks splits the input into n chunks of about the same length.
+// Every chunk starts at the keyframe closest to its even split point,
+// so the chunks can be joined without encoding.
+func splitChunks(keyframes []float64, duration float64, n int) []chunk {
+	starts := []float64{0}
+	for i := 1; i < n && len(keyframes) > 0; i++ {
+		target := duration * float64(i) / float64(n)
+		j := sort.SearchFloat64s(keyframes, target)
+		k := keyframes[len(keyframes)-1]
+		if j < len(keyframes) {
+			k = keyframes[j]
+		}
+		if j > 0 && math.Abs(keyframes[j-1]-target) < math.Abs(k-target) {
+			k = keyframes[j-1]
+		}
+		if k > starts[len(starts)-1] && k < duration {
+			starts = append(starts, k)
+		}
+	}
+	var chunks []chunk
+	for i, start := range starts {
+		end := duration
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		chunks = append(chunks, chunk{start: seconds(start), length: seconds(end - start)})
+	}
+	return chunks
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// encodeChunked encodes the video of the file in parallel chunks with
+// the same filter and bitrate, encodes the audio once for the whole file
+// and joins them into the output of the MP4 mode.
+func encodeChunked(cmdOut *modifiableOutput, file string, f ffmpeg.Filter, conf Config, e *report.Entry) error {
+	keyframes, err := ffprobe.Keyframes(conf.FFprobePath, file)
+	if err != nil {
+		return err
+	}
+	chunks := splitChunks(keyframes, e.Duration, conf.Chunks.Count)
+	log.Printf("encoding in %d chunks", len(chunks))
+
+	final := ffmpeg.NewMp4Transcoder(conf.FFmpegPath, file, conf.OutputDir, conf.Video.Bitrate, f)
+	dir := strings.TrimSuffix(final.Output(), filepath.Ext(final.Output())) + ".chunks"
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	var wg sync.WaitGroup
+	entries := make([]report.Entry, len(chunks))
+	errs := make([]error, len(chunks)+1)
+	var list strings.Builder
+	for i, c := range chunks {
+		name := fmt.Sprintf("chunk-%03d", i)
+		t := ffmpeg.NewVideoChunkTranscoder(conf.FFmpegPath, file, name+".mp4", dir, conf.Video.Bitrate, f, c.start, c.length)
+		t.PassLogFile(name)
+		// The path is quoted for the concat demuxer
+		fmt.Fprintf(&list, "file '%s'\n", strings.ReplaceAll(t.Output(), "'", `'\''`))
+
+		wg.Add(1)
+		go func(i int, t *ffmpeg.Transcoder) {
+			defer wg.Done()
+			out := &modifiableOutput{Stdout: &prefixWriter{Writer: cmdOut.Stdout, prefix: fmt.Sprintf("[chunk %03d] ", i)}}
+			errs[i] = runPasses(out, t, conf, &entries[i], nil)
+		}(i, t)
+	}
+
+	// Matroska holds every audio codec, the audio is copied into the output
+	audio := ffmpeg.NewAudioTranscoder(conf.FFmpegPath, file, "audio.mka", dir)
+	audio.AudioFilter(f.AudioFilters()...)
+	applyAudio(audio, conf.Audio)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		out := &modifiableOutput{Stdout: &prefixWriter{Writer: cmdOut.Stdout, prefix: "[audio] "}}
+		errs[len(chunks)] = runCommand(out, audio.SinglePass(), ffmpeg.NewWarningCollector(), conf)
+	}()
+	wg.Wait()
+
+	var warnings [][]ffmpeg.Warning
+	for _, c := range entries {
+		e.FirstPassTime += c.FirstPassTime
+		e.SecondPassTime += c.SecondPassTime
+		e.Warnings = append(e.Warnings, c.Warnings...)
+		warnings = append(warnings, c.FFmpegWarnings)
+	}
+	e.FFmpegWarnings = ffmpeg.MergeWarnings(warnings...)
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	listFile := filepath.Join(dir, "chunks.txt")
+	if err := ioutil.WriteFile(listFile, []byte(list.String()), 0644); err != nil {
+		return err
+	}
+	if err := runCommand(cmdOut, ffmpeg.Concat(conf.FFmpegPath, listFile, audio.Output(), final.Output()), ffmpeg.NewWarningCollector(), conf); err != nil {
+		return err
+	}
+
+	e.Output = final.Output()
+	e.OutputSize = outputSize(final)
+	return nil
+}
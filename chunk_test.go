@@ -0,0 +1,51 @@
+package burner
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitChunks(t *testing.T) {
+	tests := []struct {
+		name      string
+		keyframes []float64
+		duration  float64
+		n         int
+		want      []chunk
+	}{
+		{
+			name:     "no keyframes",
+			duration: 100,
+			n:        4,
+			want:     []chunk{{start: 0, length: 100 * time.Second}},
+		},
+		{
+			name:      "closest keyframes",
+			keyframes: []float64{0, 20, 48, 60, 90},
+			duration:  100,
+			n:         2,
+			want: []chunk{
+				{start: 0, length: 48 * time.Second},
+				{start: 48 * time.Second, length: 52 * time.Second},
+			},
+		},
+		{
+			name:      "sparse keyframes",
+			keyframes: []float64{0, 70},
+			duration:  100,
+			n:         4,
+			want: []chunk{
+				{start: 0, length: 70 * time.Second},
+				{start: 70 * time.Second, length: 30 * time.Second},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitChunks(tt.keyframes, tt.duration, tt.n); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitChunks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/shiroi-usagi/burner/internal/burn"
 	"github.com/shiroi-usagi/burner/internal/prepare"
+	"github.com/shiroi-usagi/burner/internal/queue"
 	"github.com/shiroi-usagi/burner/internal/version"
 	"github.com/spf13/cobra"
 	"os"
@@ -21,6 +22,7 @@ func main() {
 		burn.Cmd,
 		version.Cmd,
 		prepare.Cmd,
+		queue.Cmd,
 	)
 	if err := cmd.Execute(); err != nil {
 		fmt.Println(err)
@@ -0,0 +1,29 @@
+package commandline
+
+import (
+	"regexp"
+	"strings"
+)
+
+// safeArg matches the arguments which need no quoting in a POSIX shell.
+var safeArg = regexp.MustCompile(`^[\w@%+=:,./-]+$`)
+
+// Quote quotes the argument for a POSIX shell.
+func Quote(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	if safeArg.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// QuoteArgs joins the arguments into a POSIX shell command line.
+func QuoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = Quote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,34 @@
+package commandline
+
+import "testing"
+
+func TestQuoteArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "safe",
+			args: []string{"ffmpeg", "-b:v", "1371k", "/out/file.mp4"},
+			want: "ffmpeg -b:v 1371k /out/file.mp4",
+		},
+		{
+			name: "empty",
+			args: []string{"echo", ""},
+			want: "echo ''",
+		},
+		{
+			name: "spaces and quotes",
+			args: []string{"ffmpeg", "-filter_complex", `subtitles='/in/it''s.mkv', scale=1:2`},
+			want: `ffmpeg -filter_complex 'subtitles='\''/in/it'\'''\''s.mkv'\'', scale=1:2'`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := QuoteArgs(tt.args); got != tt.want {
+				t.Errorf("QuoteArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package commandline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+)
+
+type Action string
+
+const (
+	// ActionKill stops the process and reports the message.
+	ActionKill Action = "kill"
+	// ActionWarn reports the message instead of the line.
+	ActionWarn Action = "warn"
+	// ActionCount hides the line and counts the occurrences of the message.
+	ActionCount Action = "count"
+	// ActionIgnore hides the line.
+	ActionIgnore Action = "ignore"
+)
+
+// Rule describes what happens with the lines matching Pattern.
+//
+// Message is expanded with the submatches of Pattern, e.g. $1 or ${name}.
+// An empty Message reports the line itself.
+type Rule struct {
+	Pattern string `json:"pattern"`
+	Action  Action `json:"action"`
+	Message string `json:"message"`
+}
+
+type compiledRule struct {
+	Rule
+	re *regexp.Regexp
+}
+
+// RuleSet is a compiled list of rules. The first matching rule wins.
+type RuleSet []compiledRule
+
+// LoadRules reads a JSON array of rules from the given file.
+func LoadRules(path string) (RuleSet, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return nil, fmt.Errorf("invalid rules file %s: %w", path, err)
+	}
+	return CompileRules(rules)
+}
+
+// CompileRules validates the rules and compiles their patterns.
+func CompileRules(rules []Rule) (RuleSet, error) {
+	var rs RuleSet
+	for i, r := range rules {
+		switch r.Action {
+		case ActionKill, ActionWarn, ActionCount, ActionIgnore:
+		default:
+			return nil, fmt.Errorf("rule %d: unknown action `%s`", i+1, r.Action)
+		}
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		rs = append(rs, compiledRule{Rule: r, re: re})
+	}
+	return rs, nil
+}
+
+// Handler applies the rules to the lines before passing
+// the unmatched ones to next.
+//
+// Messages of count rules are tallied into counts.
+func (rs RuleSet) Handler(next Handler, counts map[string]int) Handler {
+	return HandlerFunc(func(r Response, l string) {
+		for _, rule := range rs {
+			match := rule.re.FindStringSubmatchIndex(l)
+			if match == nil {
+				continue
+			}
+			msg := l
+			if rule.Message != "" {
+				msg = string(rule.re.ExpandString(nil, rule.Message, l, match))
+			}
+			switch rule.Action {
+			case ActionKill:
+				_ = r.Signal(os.Kill)
+				fmt.Fprintf(r.Stdout, "burner: %s", msg)
+			case ActionWarn:
+				fmt.Fprintf(r.Stdout, "burner: %s", msg)
+			case ActionCount:
+				counts[msg]++
+			}
+			return
+		}
+		next.Handle(r, l)
+	})
+}
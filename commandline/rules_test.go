@@ -0,0 +1,120 @@
+package commandline
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+type fakeSignaller struct {
+	lastSignal os.Signal
+}
+
+func (f *fakeSignaller) Signal(signal os.Signal) error {
+	f.lastSignal = signal
+	return nil
+}
+
+func TestCompileRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []Rule
+		wantErr bool
+	}{
+		{
+			name:  "valid rule",
+			rules: []Rule{{Pattern: `DTS`, Action: ActionWarn}},
+		},
+		{
+			name:    "unknown action",
+			rules:   []Rule{{Pattern: `DTS`, Action: "explode"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid pattern",
+			rules:   []Rule{{Pattern: `(`, Action: ActionKill}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := CompileRules(tt.rules); (err != nil) != tt.wantErr {
+				t.Errorf("CompileRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRuleSet_Handler(t *testing.T) {
+	rs, err := CompileRules([]Rule{
+		{Pattern: `Past duration (\d\.\d+) too large`, Action: ActionCount, Message: "past duration too large"},
+		{Pattern: `Non-monotonous DTS`, Action: ActionWarn, Message: "non-monotonous DTS"},
+		{Pattern: `^Invalid (\w+)`, Action: ActionKill, Message: "invalid $1"},
+		{Pattern: `^noise`, Action: ActionIgnore},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		line       string
+		wantOut    string
+		wantSignal os.Signal
+		wantNext   bool
+	}{
+		{
+			name: "count",
+			line: "Past duration 0.99 too large",
+		},
+		{
+			name:    "warn",
+			line:    "[mp4 @ 0x0] Application provided invalid, Non-monotonous DTS",
+			wantOut: "burner: non-monotonous DTS",
+		},
+		{
+			name:       "kill with submatch",
+			line:       "Invalid data found",
+			wantOut:    "burner: invalid data",
+			wantSignal: os.Kill,
+		},
+		{
+			name: "ignore",
+			line: "noise",
+		},
+		{
+			name:     "no match",
+			line:     "any line",
+			wantNext: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signaller := fakeSignaller{}
+			var out bytes.Buffer
+			var calledNext bool
+			counts := map[string]int{}
+			h := rs.Handler(HandlerFunc(func(_ Response, _ string) {
+				calledNext = true
+			}), counts)
+			h.Handle(Response{Signaller: &signaller, Stdout: &out}, tt.line)
+			if got := out.String(); got != tt.wantOut {
+				t.Errorf("Handle() out = %q, want %q", got, tt.wantOut)
+			}
+			if signaller.lastSignal != tt.wantSignal {
+				t.Errorf("Handle() signal = %v, want %v", signaller.lastSignal, tt.wantSignal)
+			}
+			if calledNext != tt.wantNext {
+				t.Errorf("Handle() next = %v, want %v", calledNext, tt.wantNext)
+			}
+		})
+	}
+
+	counts := map[string]int{}
+	h := rs.Handler(HandlerFunc(func(_ Response, _ string) {}), counts)
+	for i := 0; i < 3; i++ {
+		h.Handle(Response{Signaller: &fakeSignaller{}, Stdout: &bytes.Buffer{}}, "Past duration 0.99 too large")
+	}
+	if counts["past duration too large"] != 3 {
+		t.Errorf("Handle() counts = %v, want 3", counts)
+	}
+}
@@ -0,0 +1,65 @@
+package burner
+
+import (
+	"fmt"
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/ffprobe"
+	"time"
+)
+
+// The crop settings of a job besides a w:h:x:y rectangle.
+const (
+	// CropAuto detects the black bars of the input.
+	CropAuto = "auto"
+	// CropNone keeps the whole frame.
+	CropNone = "none"
+)
+
+const (
+	// cropSamples is the number of parts of the input checked for black bars.
+	cropSamples = 5
+	// cropSampleDuration is the length of a part checked for black bars.
+	cropSampleDuration = 10 * time.Second
+)
+
+// ValidateCrop checks the crop setting of a job.
+func ValidateCrop(s string) error {
+	if s == "" || s == CropAuto || s == CropNone {
+		return nil
+	}
+	_, err := ffmpeg.ParseCrop(s)
+	return err
+}
+
+// detectCrop detects the black bars of the file on several parts of the
+// input. The crop holds the picture of every part, so a dark scene does
+// not cut the picture of the others.
+//
+// The zero Crop is returned when the file has no black bars. The probed
+// streams of the file tell the size of the picture.
+func detectCrop(file string, duration float64, streams []ffprobe.Stream, conf Config) (ffmpeg.Crop, error) {
+	var crop ffmpeg.Crop
+	for _, w := range sampleWindows(duration, cropSamples, cropSampleDuration) {
+		cmd := ffmpeg.CropDetectCommand(conf.FFmpegPath, file, w.start, w.length)
+		if conf.Verbose {
+			fmt.Println(cmd)
+		}
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return ffmpeg.Crop{}, fmt.Errorf("was not able to detect crop: %w", err)
+		}
+		if c, ok := ffmpeg.ParseCropDetect(string(out)); ok {
+			crop = crop.Union(c)
+		}
+	}
+	if crop.IsZero() {
+		return crop, nil
+	}
+
+	if video := ffprobe.OfType(streams, "video"); len(video) > 0 &&
+		crop.Width >= video[0].Width && crop.Height >= video[0].Height {
+		// Nothing to remove
+		return ffmpeg.Crop{}, nil
+	}
+	return crop, nil
+}
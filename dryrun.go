@@ -0,0 +1,184 @@
+package burner
+
+import (
+	"fmt"
+	"github.com/shiroi-usagi/burner/commandline"
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/ffprobe"
+	"github.com/shiroi-usagi/burner/jobqueue"
+	"github.com/shiroi-usagi/burner/report"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// dryRun prints the plan of the jobs without executing anything, the
+// inputs are only probed. The analyses of ffmpeg are detected at encode.
+func dryRun(w io.Writer, jobs []jobqueue.Job, conf Config) {
+	conf.DryRun = true
+	for i, job := range jobs {
+		fmt.Fprintf(w, "[%03d/%03d] %s\n", i+1, len(jobs), job.Input)
+		jobConf := withSettings(conf, job.Settings)
+		factory := factoryFor(jobConf.Mode)
+		if factory == nil {
+			fmt.Fprintf(w, "  error: unknown mode `%s`\n", job.Settings.Mode)
+			continue
+		}
+		// The bitrate of the job before it is lowered by the plan
+		bitrate := jobConf.Video.Bitrate
+		var e report.Entry
+		jobConf, f, streams, err := plan(job.Input, jobConf, &e)
+		if err != nil {
+			fmt.Fprintf(w, "  error: %s\n", err)
+			continue
+		}
+		t := newTranscoder(factory, jobConf.FFmpegPath, job.Input, jobConf.OutputDir, f, jobConf)
+
+		fmt.Fprintf(w, "  mode: %s\n", jobConf.Mode.Label())
+		if e.BitrateModified {
+			fmt.Fprintf(w, "  bitrate: %s (lowered from %s)\n", jobConf.Video.Bitrate, bitrate)
+		} else {
+			fmt.Fprintf(w, "  bitrate: %s\n", jobConf.Video.Bitrate)
+		}
+		if s := jobConf.Video.Search; s.TargetVMAF != 0 {
+			fmt.Fprintf(w, "  bitrate search: VMAF %g between %s and %s\n", s.TargetVMAF, s.MinBitrate, jobConf.Video.Bitrate)
+		}
+		if !f.Crop.IsZero() {
+			fmt.Fprintf(w, "  crop: %s\n", f.Crop)
+		} else if detectsCrop(f, jobConf, e.Duration) {
+			fmt.Fprintln(w, "  crop: detected at encode")
+		}
+		if detectsInterlace(jobConf, e.Duration) {
+			fmt.Fprintln(w, "  deinterlace: detected at encode")
+		} else if e.Deinterlace != "" {
+			fmt.Fprintf(w, "  deinterlace: %s\n", e.Deinterlace)
+		}
+		if e.FrameRate != "" {
+			fmt.Fprintf(w, "  frame rate: %s\n", e.FrameRate)
+		}
+		if e.AudioCodec != "" {
+			fmt.Fprintf(w, "  audio codec: %s\n", e.AudioCodec)
+		}
+		if measuresLoudness(jobConf) {
+			fmt.Fprintf(w, "  loudness: detected at encode, normalized to %g LUFS\n", jobConf.Audio.Loudness.I)
+		}
+		if e.Tonemap != "" {
+			fmt.Fprintf(w, "  tonemap: %s\n", e.Tonemap)
+		}
+		if f.Watermark.Image != "" {
+			fmt.Fprintf(w, "  watermark: %s\n", f.Watermark.Image)
+		}
+		if jobConf.Subtitles != "" {
+			fmt.Fprintf(w, "  subtitles: %s\n", jobConf.Subtitles)
+		}
+		for _, language := range e.Softsubs {
+			fmt.Fprintf(w, "  softsub: %s\n", language)
+		}
+		for _, b := range f.Intro {
+			fmt.Fprintf(w, "  intro: %s\n", b.Path)
+		}
+		for _, b := range f.Outro {
+			fmt.Fprintf(w, "  outro: %s\n", b.Path)
+		}
+		printTracks(w, streams, f, jobConf)
+		fmt.Fprintf(w, "  output: %s\n", t.Output())
+		if t.Remux() {
+			fmt.Fprintf(w, "  remux: %s\n", commandLine(t.SinglePass()))
+			continue
+		}
+		fmt.Fprintf(w, "  first pass: %s\n", commandLine(t.FirstPass()))
+		fmt.Fprintf(w, "  second pass: %s\n", commandLine(t.SecondPass()))
+	}
+}
+
+// printTracks prints the subtitle and audio tracks which end up in the
+// output, from the streams probed by the plan.
+func printTracks(w io.Writer, streams []ffprobe.Stream, f ffmpeg.Filter, conf Config) {
+	if conf.FFprobePath == "" {
+		fmt.Fprintln(w, "  tracks: unknown, ffprobe is not available")
+		return
+	}
+	if len(streams) == 0 {
+		fmt.Fprintln(w, "  tracks: unknown, the input was not probed")
+		return
+	}
+	subtitles, audio := ffprobe.OfType(streams, "subtitle"), ffprobe.OfType(streams, "audio")
+	if conf.Mode == ModeTranscode {
+		fmt.Fprintf(w, "  subtitle: %s\n", describeStreams(subtitles))
+		fmt.Fprintf(w, "  audio: %s\n", describeStreams(audio))
+		return
+	}
+	// The subtitles filter renders the first subtitle stream
+	if conf.Subtitles.Burns() && len(subtitles) > 0 {
+		fmt.Fprintf(w, "  subtitle: %s (burned)\n", describeStreams(subtitles[:1]))
+	}
+	if len(f.Softsub.Streams) > 0 {
+		codec := "mov_text"
+		if conf.Mode == ModeFragmentedMP4 {
+			codec = "WebVTT"
+		}
+		var muxed []ffprobe.Stream
+		for _, s := range subtitles {
+			for _, m := range f.Softsub.Streams {
+				if m.Map == fmt.Sprintf("0:%d", s.Index) {
+					muxed = append(muxed, s)
+				}
+			}
+		}
+		fmt.Fprintf(w, "  subtitle: %s (soft, %s)\n", describeStreams(muxed), codec)
+	}
+	switch {
+	case len(subtitles) == 0:
+		fmt.Fprintln(w, "  subtitle: none")
+	case !conf.Subtitles.Burns() && len(f.Softsub.Streams) == 0:
+		fmt.Fprintln(w, "  subtitle: none (dropped)")
+	}
+	var selected []ffprobe.Stream
+	if s, ok := defaultAudio(audio); ok {
+		selected = append(selected, s)
+	}
+	fmt.Fprintf(w, "  audio: %s\n", describeStreams(selected))
+}
+
+// defaultAudio is the audio stream selected by ffmpeg without a mapping,
+// the one with the most channels.
+func defaultAudio(streams []ffprobe.Stream) (ffprobe.Stream, bool) {
+	var selected ffprobe.Stream
+	var found bool
+	for _, s := range ffprobe.OfType(streams, "audio") {
+		if !found || s.Channels > selected.Channels {
+			selected, found = s, true
+		}
+	}
+	return selected, found
+}
+
+func describeStreams(streams []ffprobe.Stream) string {
+	if len(streams) == 0 {
+		return "none"
+	}
+	var descriptions []string
+	for _, s := range streams {
+		d := fmt.Sprintf("#%d %s", s.Index, s.CodecName)
+		if s.Tags.Language != "" {
+			d += " " + s.Tags.Language
+		}
+		if s.Tags.Title != "" {
+			d += fmt.Sprintf(" %q", s.Tags.Title)
+		}
+		if s.Channels > 0 {
+			d += fmt.Sprintf(" %dch", s.Channels)
+		}
+		descriptions = append(descriptions, d)
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// commandLine is the shell representation of cmd, including its working directory.
+func commandLine(cmd *exec.Cmd) string {
+	line := commandline.QuoteArgs(cmd.Args)
+	if cmd.Dir != "" {
+		line = fmt.Sprintf("cd %s && %s", commandline.Quote(cmd.Dir), line)
+	}
+	return line
+}
@@ -0,0 +1,178 @@
+package burner
+
+import (
+	"bytes"
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/jobqueue"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// probeOutput is printed by the fake ffprobe of TestDryRun, it holds
+// the duration and the streams of the input.
+const probeOutput = `{
+  "format": {"duration": "1420.5"},
+  "streams": [
+    {"index": 0, "codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080, "bit_rate": "1000000", "r_frame_rate": "24000/1001", "avg_frame_rate": "24000/1001"},
+    {"index": 1, "codec_type": "audio", "codec_name": "aac", "channels": 2, "tags": {"language": "jpn"}},
+    {"index": 2, "codec_type": "subtitle", "codec_name": "ass", "tags": {"language": "eng"}}
+  ]
+}`
+
+// fakeTools writes a fake ffprobe which prints the probeOutput and a
+// fake ffmpeg which fails, it leaves the returned mark when it is run.
+func fakeTools(t *testing.T, dir string) (fakeFFmpeg, fakeFFprobe, mark string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake executables are shell scripts")
+	}
+	mark = filepath.Join(dir, "ffmpeg-was-executed")
+	fakeFFmpeg = filepath.Join(dir, "ffmpeg")
+	fakeFFprobe = filepath.Join(dir, "ffprobe")
+	scripts := map[string]string{
+		fakeFFmpeg:  "#!/bin/sh\ntouch '" + mark + "'\nexit 1\n",
+		fakeFFprobe: "#!/bin/sh\ncat <<'EOF'\n" + probeOutput + "\nEOF\n",
+	}
+	for path, script := range scripts {
+		if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return fakeFFmpeg, fakeFFprobe, mark
+}
+
+func TestDryRun(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "TestDryRun")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	// The dry run must not execute ffmpeg
+	fakeFFmpeg, fakeFFprobe, mark := fakeTools(t, tmpDir)
+
+	// Without ffprobe the plan does not read the inputs
+	conf := Config{
+		Mode:       ModeMP4,
+		FFmpegPath: fakeFFmpeg,
+		OutputDir:  "/out",
+		Video:      VideoConf{Height: 720, Bitrate: 1371 * ffmpeg.Kilobit, KeepBitrate: true},
+		Audio:      AudioConf{Bitrate: 128 * ffmpeg.Kilobit},
+	}
+	probed := conf
+	probed.FFprobePath = fakeFFprobe
+	probed.Video.KeepBitrate = false
+	analysed := probed
+	analysed.Video.AutoCrop = true
+	analysed.Video.Deinterlace = SwitchAuto
+	analysed.Audio.Loudnorm = true
+	analysed.Audio.Loudness = ffmpeg.LoudnessTarget{I: -16, TP: -1.5, LRA: 11}
+	soft := probed
+	soft.Subtitles = SubtitlesSoft
+	none := probed
+	none.Subtitles = SubtitlesNone
+
+	tests := []struct {
+		name    string
+		conf    Config
+		job     jobqueue.Job
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "mp4",
+			conf: conf,
+			job:  jobqueue.Job{Input: "/in/ep01.mkv"},
+			want: []string{
+				"[001/001] /in/ep01.mkv\n",
+				"  mode: MP4 (mux)\n",
+				"  bitrate: 1371k\n",
+				"  tracks: unknown, ffprobe is not available\n",
+				"  output: /out/ep01.mp4\n",
+				"  first pass: cd /out && " + fakeFFmpeg + " -y ",
+				" -pass 1 ",
+				"  second pass: cd /out && " + fakeFFmpeg + " ",
+				" -pass 2 ",
+			},
+			notWant: []string{"  crop:", "  remux:", "  error:"},
+		},
+		{
+			name: "job settings",
+			conf: conf,
+			job: jobqueue.Job{Input: "/in/ep02.mkv", Settings: jobqueue.Settings{
+				Mode: "transcode", Bitrate: "2M", Crop: "1920:800:0:140", Subtitles: "none",
+			}},
+			want: []string{
+				"  mode: Transcode (softsub)\n",
+				"  bitrate: 2M\n",
+				"  crop: 1920:800:0:140\n",
+				"  subtitles: none\n",
+				"  output: /out/ep02.mkv\n",
+				"'crop=1920:800:0:140, ",
+			},
+			notWant: []string{"subtitles='"},
+		},
+		{
+			name:    "unknown mode",
+			conf:    conf,
+			job:     jobqueue.Job{Input: "/in/ep03.mkv", Settings: jobqueue.Settings{Mode: "bogus"}},
+			want:    []string{"[001/001] /in/ep03.mkv\n", "  error: unknown mode `bogus`\n"},
+			notWant: []string{"  output:", "  first pass:"},
+		},
+		{
+			name: "analyses are detected at encode",
+			conf: analysed,
+			job:  jobqueue.Job{Input: "/in/ep04.mkv"},
+			want: []string{
+				"  crop: detected at encode\n",
+				"  deinterlace: detected at encode\n",
+				"  loudness: detected at encode, normalized to -16 LUFS\n",
+				"  subtitle: #2 ass eng (burned)\n",
+				"  audio: #1 aac jpn 2ch\n",
+			},
+			notWant: []string{"crop=", "loudnorm"},
+		},
+		{
+			name: "lowered job bitrate",
+			conf: probed,
+			job:  jobqueue.Job{Input: "/in/ep05.mkv", Settings: jobqueue.Settings{Bitrate: "3M"}},
+			want: []string{"  bitrate: 1M (lowered from 3M)\n"},
+		},
+		{
+			name:    "soft subtitles",
+			conf:    soft,
+			job:     jobqueue.Job{Input: "/in/ep06.mkv"},
+			want:    []string{"  subtitle: #2 ass eng (soft, mov_text)\n"},
+			notWant: []string{"(burned)"},
+		},
+		{
+			name:    "dropped subtitles",
+			conf:    none,
+			job:     jobqueue.Job{Input: "/in/ep07.mkv"},
+			want:    []string{"  subtitle: none (dropped)\n"},
+			notWant: []string{"(burned)", "(soft"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			dryRun(&buf, []jobqueue.Job{tt.job}, tt.conf)
+			got := buf.String()
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("dryRun() = %s, want to contain %q", got, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("dryRun() = %s, want not to contain %q", got, notWant)
+				}
+			}
+			if _, err := os.Stat(mark); err == nil {
+				t.Errorf("dryRun() executed ffmpeg")
+			}
+		})
+	}
+}
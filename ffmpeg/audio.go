@@ -0,0 +1,24 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The audio codecs of the outputs.
+const (
+	AudioCodecAAC  = "aac"
+	AudioCodecOpus = "libopus"
+	AudioCodecAC3  = "ac3"
+	// AudioCodecCopy copies the audio without encoding.
+	AudioCodecCopy = "copy"
+)
+
+// ParseAudioCodec parses the name of an audio codec, e.g. libopus.
+func ParseAudioCodec(s string) (string, error) {
+	switch c := strings.ToLower(strings.TrimSpace(s)); c {
+	case AudioCodecAAC, AudioCodecOpus, AudioCodecAC3, AudioCodecCopy:
+		return c, nil
+	}
+	return "", fmt.Errorf("unknown audio codec `%s`, expected aac, libopus, ac3 or copy", s)
+}
@@ -0,0 +1,54 @@
+package ffmpeg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTranscoder_Audio(t *testing.T) {
+	f := Filter{Downmix: "stereo|FL=FC+0.30*FL|FR=FC+0.30*FR"}
+	tests := []struct {
+		name   string
+		apply  func(tr *Transcoder)
+		want   string
+		absent []string
+	}{
+		{
+			name:  "preset",
+			apply: func(tr *Transcoder) {},
+			want:  "-af pan=stereo|FL=FC+0.30*FL|FR=FC+0.30*FR -c:a aac -b:a 128k -ac 2",
+		},
+		{
+			name: "replaced",
+			apply: func(tr *Transcoder) {
+				tr.AudioCodec(AudioCodecOpus)
+				tr.AudioBitrate("96k")
+				tr.AudioChannels("6")
+				tr.AudioSampleRate("48000")
+			},
+			want:   "-c:a libopus -b:a 96k -ac 6 -sn -ar 48000",
+			absent: []string{"-c:a aac", "-ac 2"},
+		},
+		{
+			name:   "copy",
+			apply:  func(tr *Transcoder) { tr.AudioCopy() },
+			want:   "-c:a copy",
+			absent: []string{"-b:a", "-ac", "-af"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := NewMp4Transcoder("ffmpeg", "/in/file.mkv", "/out", Megabit, f)
+			tt.apply(tr)
+			got := strings.Join(tr.SecondPass().Args, " ")
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("second pass = %s, want %s", got, tt.want)
+			}
+			for _, a := range tt.absent {
+				if strings.Contains(got, a) {
+					t.Errorf("second pass = %s, want no %s", got, a)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Bitrate is a bitrate in bits per second.
+//
+// It implements the flag.Value interface of pflag, so it can be used
+// as a command line flag.
+type Bitrate int64
+
+const (
+	Bit     Bitrate = 1
+	Kilobit         = 1000 * Bit
+	Megabit         = 1000 * Kilobit
+	Gigabit         = 1000 * Megabit
+	Kibibit         = 1024 * Bit
+	Mebibit         = 1024 * Kibibit
+	Gibibit         = 1024 * Mebibit
+)
+
+// bitrateUnits are the suffixes of ParseBitrate, the longer
+// suffixes are first.
+var bitrateUnits = []struct {
+	suffix string
+	unit   Bitrate
+}{
+	{"Ki", Kibibit},
+	{"Mi", Mebibit},
+	{"Gi", Gibibit},
+	{"k", Kilobit},
+	{"K", Kilobit},
+	{"M", Megabit},
+	{"G", Gigabit},
+}
+
+// ParseBitrate parses a bitrate in bits per second with an optional
+// suffix, e.g. 1500000, 1371k or 1.5M. The k, M and G suffixes are
+// powers of 1000 like in ffmpeg, Ki, Mi and Gi are powers of 1024.
+func ParseBitrate(s string) (Bitrate, error) {
+	v := strings.TrimSpace(s)
+	unit := Bit
+	for _, u := range bitrateUnits {
+		if strings.HasSuffix(v, u.suffix) {
+			v = strings.TrimSuffix(v, u.suffix)
+			unit = u.unit
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f <= 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, fmt.Errorf("invalid bitrate `%s`, expected e.g. 1500000, 1371k, 1.5M or 2Mi", s)
+	}
+	b := math.Round(f * float64(unit))
+	if b < 1 || b > math.MaxInt64/2 {
+		return 0, fmt.Errorf("bitrate `%s` is out of range", s)
+	}
+	return Bitrate(b), nil
+}
+
+// String formats the bitrate for ffmpeg with the largest
+// SI suffix which keeps it exact.
+func (b Bitrate) String() string {
+	switch {
+	case b != 0 && b%Megabit == 0:
+		return fmt.Sprintf("%dM", b/Megabit)
+	case b != 0 && b%Kilobit == 0:
+		return fmt.Sprintf("%dk", b/Kilobit)
+	}
+	return strconv.FormatInt(int64(b), 10)
+}
+
+// Kilobits is the bitrate in kilobits per second.
+func (b Bitrate) Kilobits() float64 {
+	return float64(b) / float64(Kilobit)
+}
+
+// Truncate rounds the bitrate down to a multiple of m.
+func (b Bitrate) Truncate(m Bitrate) Bitrate {
+	if m <= 0 {
+		return b
+	}
+	return b - b%m
+}
+
+// Set parses the bitrate of a command line flag.
+func (b *Bitrate) Set(s string) error {
+	v, err := ParseBitrate(s)
+	if err != nil {
+		return err
+	}
+	*b = v
+	return nil
+}
+
+// Type is the name of the value in the usage of a command line flag.
+func (b *Bitrate) Type() string {
+	return "bitrate"
+}
+
+// MarshalText formats the bitrate as in String.
+func (b Bitrate) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// UnmarshalText parses the bitrate as in ParseBitrate.
+func (b *Bitrate) UnmarshalText(text []byte) error {
+	return b.Set(string(text))
+}
@@ -0,0 +1,78 @@
+package ffmpeg
+
+import (
+	"testing"
+)
+
+func TestParseBitrate(t *testing.T) {
+	tests := []struct {
+		name    string
+		bitrate string
+		want    Bitrate
+		wantErr bool
+	}{
+		{name: "bits", bitrate: "1500000", want: 1500000},
+		{name: "kilobit", bitrate: "1371k", want: 1371000},
+		{name: "uppercase kilobit", bitrate: "128K", want: 128000},
+		{name: "megabit", bitrate: "1M", want: 1000000},
+		{name: "decimal megabit", bitrate: "1.5M", want: 1500000},
+		{name: "gigabit", bitrate: "2G", want: 2000000000},
+		{name: "kibibit", bitrate: "1Ki", want: 1024},
+		{name: "mebibit", bitrate: "1Mi", want: 1048576},
+		{name: "decimal mebibit", bitrate: "0.5Mi", want: 524288},
+		{name: "spaces", bitrate: " 800k ", want: 800000},
+		{name: "empty", bitrate: "", wantErr: true},
+		{name: "unknown suffix", bitrate: "1371x", wantErr: true},
+		{name: "only suffix", bitrate: "k", wantErr: true},
+		{name: "negative", bitrate: "-1M", wantErr: true},
+		{name: "zero", bitrate: "0", wantErr: true},
+		{name: "below one bit", bitrate: "0.1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBitrate(tt.bitrate)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBitrate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseBitrate() = %v, want %v", int64(got), int64(tt.want))
+			}
+		})
+	}
+}
+
+func TestBitrate_String(t *testing.T) {
+	tests := []struct {
+		name    string
+		bitrate Bitrate
+		want    string
+	}{
+		{name: "zero", bitrate: 0, want: "0"},
+		{name: "bits", bitrate: 1500, want: "1500"},
+		{name: "kilobit", bitrate: 1371 * Kilobit, want: "1371k"},
+		{name: "megabit", bitrate: 2 * Megabit, want: "2M"},
+		{name: "decimal megabit", bitrate: 1500 * Kilobit, want: "1500k"},
+		{name: "mebibit", bitrate: Mebibit, want: "1048576"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.bitrate.String(); got != tt.want {
+				t.Errorf("String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBitrate_UnmarshalText(t *testing.T) {
+	var b Bitrate
+	if err := b.UnmarshalText([]byte("1.5M")); err != nil {
+		t.Fatal(err)
+	}
+	text, err := b.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(text) != "1500k" {
+		t.Errorf("MarshalText() = %s, want 1500k", text)
+	}
+}
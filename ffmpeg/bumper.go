@@ -0,0 +1,106 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"github.com/shiroi-usagi/burner/filtergraph"
+	"strconv"
+	"time"
+)
+
+// Bumper is a clip joined before or after the input, e.g. the intro of
+// a group or a sponsor card.
+type Bumper struct {
+	// Path of the clip
+	Path string
+	// Silent clips have no audio stream, a silence of their Duration is
+	// joined instead
+	Silent bool
+	// Duration of the clip, zero when it is not probed
+	Duration time.Duration
+}
+
+// The audio of the joined parts, the outputs are downmixed to stereo.
+const (
+	audioSampleRate     = "48000"
+	concatChannelLayout = "stereo"
+)
+
+// concat joins the bumpers with the frames of main, the bumpers are
+// read from the inputs of the command starting at next.
+//
+// The bumpers are sized to the frames of main, the parts of the output
+// have square pixels. Anamorphic frames of main are stretched to their
+// display width first.
+func (f Filter) concat(g *filtergraph.Graph, main *filtergraph.Chain, next int) {
+	ref := "v"
+	g.Add(main.Add(StageConcat,
+		filtergraph.New("scale").Opt("w", "trunc(iw*sar/2)*2").Opt("h", "ih"),
+		filtergraph.New("setsar").Arg("1"),
+	).Output(ref))
+
+	var video, audio []string
+	audioFormat := filtergraph.New("aformat").
+		Opt("sample_rates", audioSampleRate).
+		Opt("channel_layouts", concatChannelLayout)
+	bumper := func(b Bumper) {
+		in, name := strconv.Itoa(next), fmt.Sprintf("b%d", next)
+		next++
+
+		// The reference passes through unchanged
+		sized := ref + "s"
+		g.Add(filtergraph.NewChain(in+":v", ref).
+			Add(0, filtergraph.New("scale2ref").Opt("w", "main_w").Opt("h", "main_h")).
+			Output(name+"s", sized))
+		ref = sized
+
+		v := filtergraph.NewChain(name+"s").Add(0, filtergraph.New("setsar").Arg("1"))
+		if !f.FrameRate.IsZero() {
+			v.Add(0, filtergraph.New("fps").Arg(f.FrameRate.String()))
+		}
+		g.Add(v.Add(0, filtergraph.New("format").Arg("yuv420p")).Output(name + "v"))
+		video = append(video, name+"v")
+
+		if f.AudioInput == "" {
+			return
+		}
+		if b.Silent {
+			g.Add(filtergraph.NewChain().
+				Add(0, filtergraph.New("anullsrc").Opt("r", audioSampleRate).Opt("cl", concatChannelLayout)).
+				Add(0, filtergraph.New("atrim").Opt("duration", formatSeconds(b.Duration))).
+				Output(name + "a"))
+		} else {
+			g.Add(filtergraph.NewChain(in+":a").Add(0, audioFormat).Output(name + "a"))
+		}
+		audio = append(audio, name+"a")
+	}
+
+	for _, b := range f.Intro {
+		bumper(b)
+	}
+	// The main part is the reference, once it passed through the outros
+	mainVideo := len(video)
+	video = append(video, "")
+	if f.AudioInput != "" {
+		g.Add(filtergraph.NewChain(f.AudioInput).Add(0, f.AudioFilters()...).Add(0, audioFormat).Output("a"))
+		audio = append(audio, "a")
+	}
+	for _, b := range f.Outro {
+		bumper(b)
+	}
+	video[mainVideo] = ref
+
+	var pads []string
+	for i := range video {
+		pads = append(pads, video[i])
+		if f.AudioInput != "" {
+			pads = append(pads, audio[i])
+		}
+	}
+	a := "0"
+	if f.AudioInput != "" {
+		a = "1"
+	}
+	// The outputs are left unlabelled, so they are mapped to the output
+	g.Add(filtergraph.NewChain(pads...).Add(0, filtergraph.New("concat").
+		Opt("n", strconv.Itoa(len(video))).Opt("v", "1").Opt("a", a)))
+}
@@ -0,0 +1,81 @@
+package ffmpeg
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBumper_Graph(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   string
+	}{
+		{
+			name:   "intro",
+			filter: Filter{Subtitle: "/in/file.mkv", Height: 720, Width: -2, Upscaling: true, Intro: []Bumper{{Path: "/in/intro.mp4"}}, AudioInput: "0:1", FrameRate: NewFrameRate(24000, 1001)},
+			want: `[0:v]subtitles='/in/file.mkv', scale=-2:720, scale=w=trunc(iw*sar/2)*2:h=ih, setsar=1[v];` +
+				`[1:v][v]scale2ref=w=main_w:h=main_h[b1s][vs];[b1s]setsar=1, fps=24000/1001, format=yuv420p[b1v];` +
+				`[1:a]aformat=sample_rates=48000:channel_layouts=stereo[b1a];` +
+				`[0:1]aformat=sample_rates=48000:channel_layouts=stereo[a];` +
+				`[b1v][b1a][vs][a]concat=n=2:v=1:a=1`,
+		},
+		{
+			name:   "silent outro without audio",
+			filter: Filter{Outro: []Bumper{{Path: "/in/card.mp4", Silent: true, Duration: 5 * time.Second}}},
+			want: `[0:v]scale=w=trunc(iw*sar/2)*2:h=ih, setsar=1[v];` +
+				`[1:v][v]scale2ref=w=main_w:h=main_h[b1s][vs];[b1s]setsar=1, format=yuv420p[b1v];` +
+				`[vs][b1v]concat=n=2:v=1:a=0`,
+		},
+		{
+			name:   "silent outro",
+			filter: Filter{Outro: []Bumper{{Path: "/in/card.mp4", Silent: true, Duration: 5 * time.Second}}, AudioInput: "0:a:0"},
+			want: `[0:v]scale=w=trunc(iw*sar/2)*2:h=ih, setsar=1[v];` +
+				`[0:a:0]aformat=sample_rates=48000:channel_layouts=stereo[a];` +
+				`[1:v][v]scale2ref=w=main_w:h=main_h[b1s][vs];[b1s]setsar=1, format=yuv420p[b1v];` +
+				`anullsrc=r=48000:cl=stereo, atrim=duration=5[b1a];` +
+				`[vs][a][b1v][b1a]concat=n=2:v=1:a=1`,
+		},
+		{
+			name:   "intro and outro after the watermark",
+			filter: Filter{Watermark: Watermark{Image: "/in/logo.png"}, Intro: []Bumper{{Path: "/in/intro.mp4"}}, Outro: []Bumper{{Path: "/in/card.mp4"}}},
+			want: `[0:v]null[main];[1:v]format=rgba[logo];[main][logo]overlay=x=main_w-overlay_w:y=main_h-overlay_h:shortest=1, scale=w=trunc(iw*sar/2)*2:h=ih, setsar=1[v];` +
+				`[2:v][v]scale2ref=w=main_w:h=main_h[b2s][vs];[b2s]setsar=1, format=yuv420p[b2v];` +
+				`[3:v][vs]scale2ref=w=main_w:h=main_h[b3s][vss];[b3s]setsar=1, format=yuv420p[b3v];` +
+				`[b2v][vss][b3v]concat=n=3:v=1:a=0`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.String(); got != tt.want {
+				t.Errorf("String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranscoder_Bumpers(t *testing.T) {
+	f := Filter{Intro: []Bumper{{Path: "/in/intro.mp4"}}, AudioInput: "0:a:0"}
+	tr := NewMp4Transcoder("ffmpeg", "/in/file.mkv", "/out", Megabit, f)
+
+	graph := func(args []string) string {
+		for i, arg := range args {
+			if arg == "-filter_complex" {
+				return args[i+1]
+			}
+		}
+		return ""
+	}
+	first := strings.Join(tr.FirstPass().Args, " ")
+	second := strings.Join(tr.SecondPass().Args, " ")
+	if !strings.Contains(first, "-i /in/intro.mp4") || !strings.Contains(second, "-i /in/intro.mp4") {
+		t.Errorf("bumper is not an input of both passes: %s, %s", first, second)
+	}
+	if g := graph(tr.FirstPass().Args); !strings.HasSuffix(g, "concat=n=2:v=1:a=0") {
+		t.Errorf("first pass graph = %s, want the video only", g)
+	}
+	if g := graph(tr.SecondPass().Args); !strings.HasSuffix(g, "concat=n=2:v=1:a=1") {
+		t.Errorf("second pass graph = %s, want the audio joined", g)
+	}
+}
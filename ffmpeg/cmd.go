@@ -7,6 +7,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 func Printer() commandline.Handler {
@@ -81,3 +82,121 @@ func KillOnReplacedMissingFontLine(next commandline.Handler) commandline.Handler
 		next.Handle(r, l)
 	})
 }
+
+// Warning is a de-duplicated line of the ffmpeg output.
+type Warning struct {
+	// Kind classifies the warning, e.g. glyph, font or other.
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+	// First is the output timestamp of the first occurrence.
+	First string `json:"first,omitempty"`
+}
+
+const (
+	WarningGlyph = "glyph"
+	WarningFont  = "font"
+	WarningOther = "other"
+)
+
+// contextMatcher matches the `[name @ address]` prefix of
+// the log lines, the address changes between runs.
+var contextMatcher = regexp.MustCompile(`^\[(\w+) @ \w+] `)
+
+// classify returns the kind and the normalized message of the line.
+func classify(l string) (string, string) {
+	if match := glyphNotFoundMatcher.FindStringSubmatch(l); len(match) > 0 {
+		i, err := strconv.ParseInt(match[1], 16, 64)
+		if err == nil {
+			return WarningGlyph, fmt.Sprintf("glyph `%s` (0x%s) not found", string(rune(i)), match[1])
+		}
+	}
+	for prefix, matcher := range fontReplacementMatchers {
+		match := matcher.FindStringSubmatch(l)
+		if len(match) > 0 && !strings.HasPrefix(match[1], prefix) {
+			return WarningFont, fmt.Sprintf("font `%s` replaced with %s", match[1], prefix)
+		}
+	}
+	return WarningOther, contextMatcher.ReplaceAllString(l, "[$1] ")
+}
+
+// WarningCollector classifies and de-duplicates the lines of the
+// ffmpeg output.
+type WarningCollector struct {
+	mu       sync.Mutex
+	position string
+	warnings []*Warning
+	index    map[string]*Warning
+}
+
+func NewWarningCollector() *WarningCollector {
+	return &WarningCollector{index: map[string]*Warning{}}
+}
+
+// SetPosition sets the output timestamp of the lines collected from now on.
+func (c *WarningCollector) SetPosition(p string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.position = p
+}
+
+// Handler collects every line and passes the first
+// occurrence of each warning to next.
+func (c *WarningCollector) Handler(next commandline.Handler) commandline.Handler {
+	return commandline.HandlerFunc(func(r commandline.Response, l string) {
+		if strings.TrimSpace(l) == "" {
+			return
+		}
+		kind, msg := classify(l)
+		c.mu.Lock()
+		key := kind + "\x00" + msg
+		w, ok := c.index[key]
+		if !ok {
+			w = &Warning{Kind: kind, Message: msg, First: c.position}
+			c.index[key] = w
+			c.warnings = append(c.warnings, w)
+		}
+		w.Count++
+		c.mu.Unlock()
+		if !ok {
+			next.Handle(r, l)
+		}
+	})
+}
+
+// Warnings returns the collected warnings in order of appearance.
+func (c *WarningCollector) Warnings() []Warning {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	warnings := make([]Warning, 0, len(c.warnings))
+	for _, w := range c.warnings {
+		warnings = append(warnings, *w)
+	}
+	return warnings
+}
+
+// MergeWarnings merges the warnings of several runs over the same
+// input, like the passes of an encode. Each run sees the same
+// warnings, so the highest count and the earliest timestamp is kept.
+func MergeWarnings(runs ...[]Warning) []Warning {
+	var merged []Warning
+	index := map[string]int{}
+	for _, run := range runs {
+		for _, w := range run {
+			key := w.Kind + "\x00" + w.Message
+			i, ok := index[key]
+			if !ok {
+				index[key] = len(merged)
+				merged = append(merged, w)
+				continue
+			}
+			if w.Count > merged[i].Count {
+				merged[i].Count = w.Count
+			}
+			if merged[i].First == "" || (w.First != "" && w.First < merged[i].First) {
+				merged[i].First = w.First
+			}
+		}
+	}
+	return merged
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/shiroi-usagi/burner/commandline"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -149,3 +150,49 @@ func TestKillOnReplacedMissingFontLine(t *testing.T) {
 		})
 	}
 }
+
+func TestWarningCollector_Handler(t *testing.T) {
+	r := commandline.Response{Signaller: &fakeSignaller{}, Stdout: ioutil.Discard}
+	var next int
+	c := NewWarningCollector()
+	pipe := c.Handler(commandline.HandlerFunc(func(_ commandline.Response, _ string) {
+		next++
+	}))
+
+	c.SetPosition("00:00:01.000000")
+	pipe.Handle(r, `[Parsed_subtitles_0 @ 0000a] Glyph 0x266F not found, selecting one more font for (anystring, 0, 0)`)
+	c.SetPosition("00:00:02.000000")
+	pipe.Handle(r, `[Parsed_subtitles_0 @ 0000b] Glyph 0x266F not found, selecting one more font for (anystring, 0, 0)`)
+	pipe.Handle(r, `[Parsed_subtitles_0 @ 0000b] fontselect: (Teszt1, 400, 0) -> ArialMT, 0, ArialMT`)
+	pipe.Handle(r, `[mp4 @ 0000c] Non-monotonous DTS`)
+	pipe.Handle(r, ``)
+
+	want := []Warning{
+		{Kind: WarningGlyph, Message: "glyph `♯` (0x266F) not found", Count: 2, First: "00:00:01.000000"},
+		{Kind: WarningFont, Message: "font `Teszt1` replaced with Arial", Count: 1, First: "00:00:02.000000"},
+		{Kind: WarningOther, Message: "[mp4] Non-monotonous DTS", Count: 1, First: "00:00:02.000000"},
+	}
+	if got := c.Warnings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Warnings() = %v, want %v", got, want)
+	}
+	if next != 3 {
+		t.Errorf("should call next once per warning, called %d times", next)
+	}
+}
+
+func TestMergeWarnings(t *testing.T) {
+	first := []Warning{
+		{Kind: WarningGlyph, Message: "a", Count: 2, First: "00:00:02.000000"},
+	}
+	second := []Warning{
+		{Kind: WarningOther, Message: "b", Count: 1, First: "00:00:05.000000"},
+		{Kind: WarningGlyph, Message: "a", Count: 3, First: "00:00:01.000000"},
+	}
+	want := []Warning{
+		{Kind: WarningGlyph, Message: "a", Count: 3, First: "00:00:01.000000"},
+		{Kind: WarningOther, Message: "b", Count: 1, First: "00:00:05.000000"},
+	}
+	if got := MergeWarnings(first, second); !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeWarnings() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,110 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Crop is a rectangle of the frame in pixels.
+type Crop struct {
+	Width  int
+	Height int
+	X      int
+	Y      int
+}
+
+// ParseCrop parses a crop rectangle in the w:h:x:y form of the crop filter.
+func ParseCrop(s string) (Crop, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 4 {
+		return Crop{}, fmt.Errorf("invalid crop `%s`, expected w:h:x:y", s)
+	}
+	var values [4]int
+	for i, p := range parts {
+		v, err := strconv.Atoi(p)
+		if err != nil || v < 0 {
+			return Crop{}, fmt.Errorf("invalid crop `%s`, expected w:h:x:y", s)
+		}
+		values[i] = v
+	}
+	c := Crop{Width: values[0], Height: values[1], X: values[2], Y: values[3]}
+	if c.Width == 0 || c.Height == 0 {
+		return Crop{}, fmt.Errorf("invalid crop `%s`, the size is zero", s)
+	}
+	return c, nil
+}
+
+// IsZero reports whether the crop is not set.
+func (c Crop) IsZero() bool {
+	return c == Crop{}
+}
+
+// String formats the crop in the w:h:x:y form of the crop filter.
+func (c Crop) String() string {
+	return fmt.Sprintf("%d:%d:%d:%d", c.Width, c.Height, c.X, c.Y)
+}
+
+// Union is the smallest rectangle which holds both crops.
+func (c Crop) Union(o Crop) Crop {
+	if c.IsZero() {
+		return o
+	}
+	if o.IsZero() {
+		return c
+	}
+	x1, y1 := c.X, c.Y
+	if o.X < x1 {
+		x1 = o.X
+	}
+	if o.Y < y1 {
+		y1 = o.Y
+	}
+	x2, y2 := c.X+c.Width, c.Y+c.Height
+	if o.X+o.Width > x2 {
+		x2 = o.X + o.Width
+	}
+	if o.Y+o.Height > y2 {
+		y2 = o.Y + o.Height
+	}
+	return Crop{Width: x2 - x1, Height: y2 - y1, X: x1, Y: y1}
+}
+
+// CropDetectCommand builds the command which detects the black bars of
+// the part of the input starting at start with the given length.
+func CropDetectCommand(executable, input string, start, length time.Duration) *exec.Cmd {
+	var args []string
+	args = append(args, "-hide_banner", "-nostats")                   // Only print the output of the filter.
+	args = append(args, "-loglevel", "info")                          // The crop is printed as info.
+	args = append(args, "-ss", formatSeconds(start))                  // Seek in the input to the start of the part.
+	args = append(args, "-t", formatSeconds(length))                  // Limit the duration of data read from the input.
+	args = append(args, "-i", input)                                  // Input file url
+	args = append(args, "-map", "0:v:0")                              // Select the first video stream.
+	args = append(args, "-vf", "cropdetect=limit=24:round=2:reset=0") // Detect the union of the non-black areas.
+	args = append(args, "-f", "null", "-")                            // Discard the output.
+	return exec.Command(executable, args...)
+}
+
+var cropDetectMatcher = regexp.MustCompile(`\[Parsed_cropdetect_\d+ @ \w+].* crop=(\d+:\d+:\d+:\d+)`)
+
+// ParseCropDetect returns the last crop of the output of
+// CropDetectCommand, it holds the whole part of the input.
+func ParseCropDetect(out string) (Crop, bool) {
+	var crop Crop
+	var found bool
+	for _, line := range strings.Split(out, "\n") {
+		match := cropDetectMatcher.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		c, err := ParseCrop(match[1])
+		if err != nil {
+			continue
+		}
+		crop, found = c, true
+	}
+	return crop, found
+}
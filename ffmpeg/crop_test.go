@@ -0,0 +1,94 @@
+package ffmpeg
+
+import (
+	"testing"
+)
+
+func TestParseCrop(t *testing.T) {
+	tests := []struct {
+		name    string
+		crop    string
+		want    Crop
+		wantErr bool
+	}{
+		{name: "rectangle", crop: "1920:800:0:140", want: Crop{Width: 1920, Height: 800, Y: 140}},
+		{name: "missing offset", crop: "1920:800", wantErr: true},
+		{name: "zero size", crop: "0:800:0:140", wantErr: true},
+		{name: "negative", crop: "1920:800:-1:140", wantErr: true},
+		{name: "expression", crop: "iw:ih-280:0:140", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCrop(tt.crop)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCrop() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseCrop() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrop_Union(t *testing.T) {
+	tests := []struct {
+		name string
+		c, o Crop
+		want Crop
+	}{
+		{
+			name: "zero",
+			o:    Crop{Width: 1920, Height: 800, Y: 140},
+			want: Crop{Width: 1920, Height: 800, Y: 140},
+		},
+		{
+			name: "dark scene",
+			c:    Crop{Width: 1920, Height: 800, Y: 140},
+			o:    Crop{Width: 1200, Height: 600, X: 300, Y: 200},
+			want: Crop{Width: 1920, Height: 800, Y: 140},
+		},
+		{
+			name: "overlapping",
+			c:    Crop{Width: 1920, Height: 800, Y: 140},
+			o:    Crop{Width: 1440, Height: 1080, X: 240},
+			want: Crop{Width: 1920, Height: 1080},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.c.Union(tt.o); got != tt.want {
+				t.Errorf("Union() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCropDetect(t *testing.T) {
+	tests := []struct {
+		name   string
+		out    string
+		want   Crop
+		wantOK bool
+	}{
+		{
+			name: "last crop",
+			out: `[Parsed_cropdetect_0 @ 0x5581f3c0] x1:0 x2:1919 y1:142 y2:937 w:1920 h:796 x:0 y:142 pts:1001 t:0.041708 limit:0.094118 crop=1920:796:0:142
+[Parsed_cropdetect_0 @ 0x5581f3c0] x1:0 x2:1919 y1:140 y2:939 w:1920 h:800 x:0 y:140 pts:2002 t:0.083417 limit:0.094118 crop=1920:800:0:140
+[out#0/null @ 0x5581f4c0] video:2kB audio:0kB subtitle:0kB`,
+			want:   Crop{Width: 1920, Height: 800, Y: 140},
+			wantOK: true,
+		},
+		{
+			name: "no crop",
+			out:  "[in#0 @ 0x5581f3c0] Error opening input: No such file or directory",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseCropDetect(tt.out)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ParseCropDetect() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
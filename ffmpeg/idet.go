@@ -0,0 +1,86 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Deinterlacer is the filter which deinterlaces the frames.
+type Deinterlacer string
+
+const (
+	DeinterlacerNone  Deinterlacer = ""
+	DeinterlacerBwdif Deinterlacer = "bwdif"
+	DeinterlacerYadif Deinterlacer = "yadif"
+)
+
+// ParseDeinterlacer parses the name of a deinterlacer, e.g. bwdif.
+func ParseDeinterlacer(s string) (Deinterlacer, error) {
+	switch d := Deinterlacer(strings.ToLower(strings.TrimSpace(s))); d {
+	case DeinterlacerBwdif, DeinterlacerYadif:
+		return d, nil
+	}
+	return DeinterlacerNone, fmt.Errorf("unknown deinterlacer `%s`", s)
+}
+
+// IdetStats are the frame counts of the multi frame detection of idet.
+type IdetStats struct {
+	TFF          int
+	BFF          int
+	Progressive  int
+	Undetermined int
+}
+
+// Add sums the frame counts.
+func (s IdetStats) Add(o IdetStats) IdetStats {
+	return IdetStats{
+		TFF:          s.TFF + o.TFF,
+		BFF:          s.BFF + o.BFF,
+		Progressive:  s.Progressive + o.Progressive,
+		Undetermined: s.Undetermined + o.Undetermined,
+	}
+}
+
+// Interlaced is the share of the determined frames which are interlaced.
+func (s IdetStats) Interlaced() float64 {
+	total := s.TFF + s.BFF + s.Progressive
+	if total == 0 {
+		return 0
+	}
+	return float64(s.TFF+s.BFF) / float64(total)
+}
+
+// IdetCommand builds the command which detects the interlaced frames of
+// the part of the input starting at start with the given length.
+func IdetCommand(executable, input string, start, length time.Duration) *exec.Cmd {
+	var args []string
+	args = append(args, "-hide_banner", "-nostats")  // Only print the output of the filter.
+	args = append(args, "-loglevel", "info")         // The statistics are printed as info.
+	args = append(args, "-ss", formatSeconds(start)) // Seek in the input to the start of the part.
+	args = append(args, "-t", formatSeconds(length)) // Limit the duration of data read from the input.
+	args = append(args, "-i", input)                 // Input file url
+	args = append(args, "-map", "0:v:0")             // Select the first video stream.
+	args = append(args, "-vf", "idet")               // Detect the interlaced frames.
+	args = append(args, "-f", "null", "-")           // Discard the output.
+	return exec.Command(executable, args...)
+}
+
+var idetMatcher = regexp.MustCompile(`Multi frame detection: TFF:\s*(\d+) BFF:\s*(\d+) Progressive:\s*(\d+) Undetermined:\s*(\d+)`)
+
+// ParseIdet returns the multi frame detection of the output of IdetCommand.
+func ParseIdet(out string) (IdetStats, bool) {
+	match := idetMatcher.FindAllStringSubmatch(out, -1)
+	if len(match) == 0 {
+		return IdetStats{}, false
+	}
+	last := match[len(match)-1]
+	var v [4]int
+	for i := range v {
+		v[i], _ = strconv.Atoi(last[i+1])
+	}
+	return IdetStats{TFF: v[0], BFF: v[1], Progressive: v[2], Undetermined: v[3]}, true
+}
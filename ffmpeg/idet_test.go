@@ -0,0 +1,35 @@
+package ffmpeg
+
+import (
+	"testing"
+)
+
+func TestParseIdet(t *testing.T) {
+	tests := []struct {
+		name   string
+		out    string
+		want   IdetStats
+		wantOK bool
+	}{
+		{
+			name: "multi frame detection",
+			out: `[Parsed_idet_0 @ 0x55d1c0] Repeated Fields: Neither:   238 Top:     3 Bottom:     0
+[Parsed_idet_0 @ 0x55d1c0] Single frame detection: TFF:    92 BFF:     0 Progressive:   121 Undetermined:    28
+[Parsed_idet_0 @ 0x55d1c0] Multi frame detection: TFF:    96 BFF:     0 Progressive:   141 Undetermined:     4`,
+			want:   IdetStats{TFF: 96, Progressive: 141, Undetermined: 4},
+			wantOK: true,
+		},
+		{
+			name: "no detection",
+			out:  "[in#0 @ 0x55d1c0] Error opening input: No such file or directory",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseIdet(tt.out)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ParseIdet() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
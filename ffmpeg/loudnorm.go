@@ -0,0 +1,140 @@
+package ffmpeg
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/shiroi-usagi/burner/filtergraph"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// LoudnessTarget is the target of the EBU R128 loudness normalization.
+type LoudnessTarget struct {
+	// Integrated loudness in LUFS
+	I float64
+	// True peak in dBTP
+	TP float64
+	// Loudness range in LU
+	LRA float64
+}
+
+// Validate checks the target against the ranges of the loudnorm filter.
+func (t LoudnessTarget) Validate() error {
+	if t.I < -70 || t.I > -5 {
+		return fmt.Errorf("invalid integrated loudness %g LUFS, expected a value between -70 and -5", t.I)
+	}
+	if t.TP < -9 || t.TP > 0 {
+		return fmt.Errorf("invalid true peak %g dBTP, expected a value between -9 and 0", t.TP)
+	}
+	if t.LRA < 1 || t.LRA > 20 {
+		return fmt.Errorf("invalid loudness range %g LU, expected a value between 1 and 20", t.LRA)
+	}
+	return nil
+}
+
+// Loudness are the values measured by the analysis pass of loudnorm.
+type Loudness struct {
+	InputI       float64 `json:"input_i"`
+	InputTP      float64 `json:"input_tp"`
+	InputLRA     float64 `json:"input_lra"`
+	InputThresh  float64 `json:"input_thresh"`
+	TargetOffset float64 `json:"target_offset"`
+}
+
+// Loudnorm normalizes the loudness of the audio with the measured
+// values, so it is applied linearly in a single pass.
+//
+// The zero value keeps the audio.
+type Loudnorm struct {
+	Target   LoudnessTarget
+	Measured Loudness
+}
+
+// IsZero reports whether the normalization is not set.
+func (l Loudnorm) IsZero() bool {
+	return l == Loudnorm{}
+}
+
+// filters are the filters of the normalization. The loudnorm filter
+// outputs 192 kHz, so the audio is resampled to the rate of the outputs.
+func (l Loudnorm) filters() []filtergraph.Filter {
+	f := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return []filtergraph.Filter{
+		l.Target.filter().
+			Opt("measured_I", f(l.Measured.InputI)).
+			Opt("measured_TP", f(l.Measured.InputTP)).
+			Opt("measured_LRA", f(l.Measured.InputLRA)).
+			Opt("measured_thresh", f(l.Measured.InputThresh)).
+			Opt("offset", f(l.Measured.TargetOffset)).
+			Opt("linear", "true"),
+		filtergraph.New("aresample").Arg(audioSampleRate),
+	}
+}
+
+func (t LoudnessTarget) filter() filtergraph.Filter {
+	return filtergraph.New("loudnorm").
+		Opt("I", strconv.FormatFloat(t.I, 'f', -1, 64)).
+		Opt("TP", strconv.FormatFloat(t.TP, 'f', -1, 64)).
+		Opt("LRA", strconv.FormatFloat(t.LRA, 'f', -1, 64))
+}
+
+// LoudnormCommand builds the command which measures the loudness of the
+// audio stream of the input, e.g. 0:1. Without a stream the audio stream
+// selected by ffmpeg is measured. The loudness is measured after the
+// downmix of the output, when it is set.
+func LoudnormCommand(executable, input, stream, downmix string, target LoudnessTarget) *exec.Cmd {
+	c := filtergraph.NewChain()
+	if downmix != "" {
+		c.Add(0, filtergraph.New("pan").Arg(downmix))
+	}
+	graph := c.Add(0, target.filter().Opt("print_format", "json")).String()
+
+	var args []string
+	args = append(args, "-hide_banner", "-nostats") // Only print the output of the filter.
+	args = append(args, "-loglevel", "info")        // The measured values are printed as info.
+	args = append(args, "-i", input)                // Input file url
+	if stream != "" {
+		args = append(args, "-map", stream) // Select the measured audio stream.
+	}
+	args = append(args, "-vn", "-sn")      // Skip the video and subtitle streams.
+	args = append(args, "-af", graph)      // Measure the loudness.
+	args = append(args, "-f", "null", "-") // Discard the output.
+	return exec.Command(executable, args...)
+}
+
+// ParseLoudnorm returns the measured values printed by LoudnormCommand.
+// Silent audio can not be normalized, it is not reported.
+func ParseLoudnorm(out string) (Loudness, bool) {
+	start := strings.LastIndex(out, "{")
+	if start < 0 {
+		return Loudness{}, false
+	}
+	end := strings.Index(out[start:], "}")
+	if end < 0 {
+		return Loudness{}, false
+	}
+	// The values are printed as strings
+	var values map[string]string
+	if err := json.Unmarshal([]byte(out[start:start+end+1]), &values); err != nil {
+		return Loudness{}, false
+	}
+	var l Loudness
+	for key, v := range map[string]*float64{
+		"input_i":       &l.InputI,
+		"input_tp":      &l.InputTP,
+		"input_lra":     &l.InputLRA,
+		"input_thresh":  &l.InputThresh,
+		"target_offset": &l.TargetOffset,
+	} {
+		f, err := strconv.ParseFloat(values[key], 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return Loudness{}, false
+		}
+		*v = f
+	}
+	return l, true
+}
@@ -0,0 +1,81 @@
+package ffmpeg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLoudnorm(t *testing.T) {
+	tests := []struct {
+		name   string
+		out    string
+		want   Loudness
+		wantOk bool
+	}{
+		{
+			name: "measured",
+			out: `Input #0, matroska,webm, from 'in.mkv':
+[Parsed_loudnorm_0 @ 0x55d5c8a3e1c0]
+{
+	"input_i" : "-27.61",
+	"input_tp" : "-4.47",
+	"input_lra" : "18.06",
+	"input_thresh" : "-39.20",
+	"output_i" : "-23.58",
+	"output_tp" : "-1.50",
+	"output_lra" : "14.78",
+	"output_thresh" : "-35.71",
+	"normalization_type" : "dynamic",
+	"target_offset" : "0.58"
+}
+`,
+			want:   Loudness{InputI: -27.61, InputTP: -4.47, InputLRA: 18.06, InputThresh: -39.2, TargetOffset: 0.58},
+			wantOk: true,
+		},
+		{
+			name: "silence",
+			out: `[Parsed_loudnorm_0 @ 0x55d5c8a3e1c0]
+{
+	"input_i" : "-inf",
+	"input_tp" : "-inf",
+	"input_lra" : "0.00",
+	"input_thresh" : "-70.00",
+	"target_offset" : "inf"
+}
+`,
+		},
+		{
+			name: "no output",
+			out:  "in.mkv: No such file or directory",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseLoudnorm(tt.out)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("ParseLoudnorm() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestTranscoder_Loudnorm(t *testing.T) {
+	l := Loudnorm{
+		Target:   LoudnessTarget{I: -23, TP: -1, LRA: 7},
+		Measured: Loudness{InputI: -27.61, InputTP: -4.47, InputLRA: 18.06, InputThresh: -39.2, TargetOffset: 0.58},
+	}
+	want := "loudnorm=I=-23:TP=-1:LRA=7:measured_I=-27.61:measured_TP=-4.47:measured_LRA=18.06:measured_thresh=-39.2:offset=0.58:linear=true, aresample=48000"
+
+	tr := NewMp4Transcoder("ffmpeg", "/in/file.mkv", "/out", Megabit, Filter{Loudnorm: l})
+	if first := strings.Join(tr.FirstPass().Args, " "); strings.Contains(first, "loudnorm") {
+		t.Errorf("first pass normalizes the audio: %s", first)
+	}
+	if second := strings.Join(tr.SecondPass().Args, " "); !strings.Contains(second, "-af "+want) {
+		t.Errorf("second pass = %s, want -af %s", second, want)
+	}
+
+	f := Filter{Loudnorm: l, Intro: []Bumper{{Path: "/in/intro.mp4"}}, AudioInput: "0:1"}
+	if got := f.String(); !strings.Contains(got, "[0:1]"+want+", aformat=") {
+		t.Errorf("String() = %s, want the audio normalized before the concatenation", got)
+	}
+}
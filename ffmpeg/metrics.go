@@ -0,0 +1,138 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"github.com/shiroi-usagi/burner/filtergraph"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metric is a full-reference video quality metric of ffmpeg.
+type Metric string
+
+const (
+	MetricVMAF Metric = "vmaf"
+	MetricSSIM Metric = "ssim"
+	MetricPSNR Metric = "psnr"
+)
+
+// filter is the name of the ffmpeg filter which measures the metric.
+func (m Metric) filter() string {
+	if m == MetricVMAF {
+		return "libvmaf"
+	}
+	return string(m)
+}
+
+// ParseMetric parses the name of a metric, e.g. vmaf.
+func ParseMetric(s string) (Metric, error) {
+	switch m := Metric(strings.ToLower(strings.TrimSpace(s))); m {
+	case MetricVMAF, MetricSSIM, MetricPSNR:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown metric `%s`", s)
+}
+
+// Scores are the results of the quality metrics, zero means the
+// metric was not measured.
+type Scores struct {
+	// VMAF is the mean VMAF score between 0 and 100.
+	VMAF float64 `json:"vmaf,omitempty"`
+	// SSIM is the SSIM of all planes between 0 and 1.
+	SSIM float64 `json:"ssim,omitempty"`
+	// PSNR is the average PSNR in dB.
+	PSNR float64 `json:"psnr,omitempty"`
+}
+
+// MetricsCommand builds the command which compares the distorted file
+// to the reference with the given metrics. The reference is scaled to
+// the size of the distorted file, after the stages of f which change
+// its frames, e.g. the crop, are applied to it.
+//
+// A non-zero length compares the distorted file to the part of the
+// reference starting at start, e.g. for samples.
+func MetricsCommand(executable, distorted, reference string, f Filter, start, length time.Duration, metrics []Metric) *exec.Cmd {
+	var args []string
+	args = append(args, "-hide_banner", "-nostats") // Only print the summary of the filters.
+	args = append(args, "-loglevel", "info")        // The scores are printed as info.
+	args = append(args, "-i", distorted)            // Input file url of the distorted file.
+	if length > 0 {
+		args = append(args, "-ss", formatSeconds(start)) // Position of the distorted file in the reference.
+		args = append(args, "-t", formatSeconds(length)) // Length of the distorted file.
+	}
+	args = append(args, "-i", reference)                             // Input file url of the reference.
+	args = append(args, "-filter_complex", metricsGraph(f, metrics)) // Compare the video streams.
+	args = append(args, "-f", "null", "-")                           // Discard the output.
+	return exec.Command(executable, args...)
+}
+
+// Reference is the filter which is applied to the reference of the
+// quality metrics, the stages of f which change the frames of the input
+// beside their size. The subtitle and the watermark are not rendered.
+func (f Filter) Reference() Filter {
+	return Filter{
+		Deinterlacer:    f.Deinterlacer,
+		InverseTelecine: f.InverseTelecine,
+		FrameRate:       f.FrameRate,
+		ConstantRate:    f.ConstantRate,
+		Crop:            f.Crop,
+		Tonemap:         f.Tonemap,
+	}
+}
+
+// metricsGraph builds the filtergraph which compares the first video
+// stream of the inputs with every metric. The reference stages of f
+// are applied to the reference.
+func metricsGraph(f Filter, metrics []Metric) string {
+	var g filtergraph.Graph
+	reference := filtergraph.NewChain("1:v").Add(0, f.Reference().Chain().Filters()...)
+	g.Add(
+		filtergraph.NewChain("0:v").Add(0, filtergraph.New("setpts").Arg("PTS-STARTPTS")).Output("d"),
+		reference.Add(0, filtergraph.New("setpts").Arg("PTS-STARTPTS"), filtergraph.New("format").Arg("yuv420p")).Output("r"),
+		filtergraph.NewChain("r", "d").Add(0, filtergraph.New("scale2ref").Opt("flags", "bicubic")).Output("ref", "dist"),
+	)
+	if len(metrics) == 1 {
+		g.Add(filtergraph.NewChain("dist", "ref").Add(0, filtergraph.New(metrics[0].filter())))
+		return g.String()
+	}
+	var d, r []string
+	for i := range metrics {
+		d = append(d, fmt.Sprintf("d%d", i))
+		r = append(r, fmt.Sprintf("r%d", i))
+	}
+	n := strconv.Itoa(len(metrics))
+	g.Add(
+		filtergraph.NewChain("dist").Add(0, filtergraph.New("split").Arg(n)).Output(d...),
+		filtergraph.NewChain("ref").Add(0, filtergraph.New("split").Arg(n)).Output(r...),
+	)
+	for i, m := range metrics {
+		g.Add(filtergraph.NewChain(d[i], r[i]).Add(0, filtergraph.New(m.filter())))
+	}
+	return g.String()
+}
+
+var (
+	vmafMatcher = regexp.MustCompile(`VMAF score: ([\d.]+)`)
+	ssimMatcher = regexp.MustCompile(`SSIM .*All:([\d.]+)`)
+	psnrMatcher = regexp.MustCompile(`PSNR .*average:([\d.]+)`)
+)
+
+// ParseScores reads the scores from the output of MetricsCommand.
+func ParseScores(out string) Scores {
+	var s Scores
+	for _, line := range strings.Split(out, "\n") {
+		if match := vmafMatcher.FindStringSubmatch(line); match != nil {
+			s.VMAF, _ = strconv.ParseFloat(match[1], 64)
+		}
+		if match := ssimMatcher.FindStringSubmatch(line); match != nil {
+			s.SSIM, _ = strconv.ParseFloat(match[1], 64)
+		}
+		if match := psnrMatcher.FindStringSubmatch(line); match != nil {
+			s.PSNR, _ = strconv.ParseFloat(match[1], 64)
+		}
+	}
+	return s
+}
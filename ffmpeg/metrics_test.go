@@ -0,0 +1,76 @@
+package ffmpeg
+
+import (
+	"testing"
+)
+
+func TestParseScores(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want Scores
+	}{
+		{
+			name: "all metrics",
+			out: `[Parsed_libvmaf_7 @ 0x55d5c1a3c2c0] VMAF score: 94.512345
+[Parsed_ssim_8 @ 0x55d5c1a3d140] SSIM Y:0.987 (18.860) U:0.991 (20.457) V:0.990 (20.000) All:0.988372 (19.345)
+[Parsed_psnr_9 @ 0x55d5c1a3e000] PSNR y:41.21 u:45.02 v:44.87 average:42.130000 min:35.40 max:50.11`,
+			want: Scores{VMAF: 94.512345, SSIM: 0.988372, PSNR: 42.13},
+		},
+		{
+			name: "single metric",
+			out:  "[Parsed_ssim_4 @ 0x55d5c1a3d140] SSIM Y:0.987 (18.860) U:0.991 (20.457) V:0.990 (20.000) All:0.988372 (19.345)\n",
+			want: Scores{SSIM: 0.988372},
+		},
+		{
+			name: "no scores",
+			out:  "[in#0 @ 0x55d5c1a3c2c0] Error opening input: No such file or directory",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseScores(tt.out); got != tt.want {
+				t.Errorf("ParseScores() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricsGraph(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  Filter
+		metrics []Metric
+		want    string
+	}{
+		{
+			name:    "single metric",
+			metrics: []Metric{MetricVMAF},
+			want:    `[0:v]setpts=PTS-STARTPTS[d];[1:v]setpts=PTS-STARTPTS, format=yuv420p[r];[r][d]scale2ref=flags=bicubic[ref][dist];[dist][ref]libvmaf`,
+		},
+		{
+			name:    "multiple metrics",
+			metrics: []Metric{MetricSSIM, MetricPSNR},
+			want:    `[0:v]setpts=PTS-STARTPTS[d];[1:v]setpts=PTS-STARTPTS, format=yuv420p[r];[r][d]scale2ref=flags=bicubic[ref][dist];[dist]split=2[d0][d1];[ref]split=2[r0][r1];[d0][r0]ssim;[d1][r1]psnr`,
+		},
+		{
+			name: "reference stages",
+			filter: Filter{
+				Subtitle: "/in/file.mkv", Height: 720, Width: -2, Watermark: Watermark{Image: "/in/logo.png"},
+				InverseTelecine: true, FrameRate: NewFrameRate(24000, 1001), ConstantRate: true,
+				Crop: Crop{Width: 1920, Height: 800, Y: 140}, Tonemap: Tonemap{Gamut: true},
+			},
+			metrics: []Metric{MetricVMAF},
+			want: `[0:v]setpts=PTS-STARTPTS[d];` +
+				`[1:v]fieldmatch, decimate, fps=24000/1001, crop=1920:800:0:140, zscale=p=bt709:t=bt709:m=bt709:r=tv, format=yuv420p, setpts=PTS-STARTPTS, format=yuv420p[r];` +
+				`[r][d]scale2ref=flags=bicubic[ref][dist];[dist][ref]libvmaf`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := metricsGraph(tt.filter, tt.metrics); got != tt.want {
+				t.Errorf("metricsGraph() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FrameRate is a frame rate as a fraction, e.g. 24000/1001.
+type FrameRate struct {
+	Num int
+	Den int
+}
+
+// NewFrameRate creates the reduced frame rate of num/den.
+func NewFrameRate(num, den int) FrameRate {
+	if num <= 0 || den <= 0 {
+		return FrameRate{}
+	}
+	a, b := num, den
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return FrameRate{Num: num / a, Den: den / a}
+}
+
+// ParseFrameRate parses a frame rate in the num/den form of ffprobe, or
+// as a whole number.
+func ParseFrameRate(s string) (FrameRate, error) {
+	num, den, found := strings.Cut(strings.TrimSpace(s), "/")
+	if !found {
+		den = "1"
+	}
+	n, err := strconv.Atoi(num)
+	if err != nil {
+		return FrameRate{}, fmt.Errorf("invalid frame rate `%s`", s)
+	}
+	d, err := strconv.Atoi(den)
+	if err != nil {
+		return FrameRate{}, fmt.Errorf("invalid frame rate `%s`", s)
+	}
+	r := NewFrameRate(n, d)
+	if r.IsZero() {
+		return FrameRate{}, fmt.Errorf("invalid frame rate `%s`", s)
+	}
+	return r, nil
+}
+
+// IsZero reports whether the frame rate is not set.
+func (r FrameRate) IsZero() bool {
+	return r == FrameRate{}
+}
+
+// Float is the frame rate in frames per second.
+func (r FrameRate) Float() float64 {
+	if r.Den == 0 {
+		return 0
+	}
+	return float64(r.Num) / float64(r.Den)
+}
+
+// Decimated is the frame rate after the inverse telecine, which drops
+// one of every five frames.
+func (r FrameRate) Decimated() FrameRate {
+	return NewFrameRate(r.Num*4, r.Den*5)
+}
+
+// String formats the frame rate in the num/den form of ffmpeg.
+func (r FrameRate) String() string {
+	if r.Den == 1 {
+		return strconv.Itoa(r.Num)
+	}
+	return fmt.Sprintf("%d/%d", r.Num, r.Den)
+}
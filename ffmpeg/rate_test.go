@@ -0,0 +1,81 @@
+package ffmpeg
+
+import (
+	"testing"
+)
+
+func TestParseFrameRate(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    FrameRate
+		wantErr bool
+	}{
+		{s: "30000/1001", want: FrameRate{Num: 30000, Den: 1001}},
+		{s: "50/2", want: FrameRate{Num: 25, Den: 1}},
+		{s: "24", want: FrameRate{Num: 24, Den: 1}},
+		{s: "0/0", wantErr: true},
+		{s: "fast", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseFrameRate(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFrameRate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseFrameRate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if got := NewFrameRate(30000, 1001).Decimated().String(); got != "24000/1001" {
+		t.Errorf("Decimated() = %s, want 24000/1001", got)
+	}
+}
+
+func TestTranscoder_FrameRate(t *testing.T) {
+	tests := []struct {
+		name      string
+		filter    Filter
+		wantGraph string
+		wantMode  string
+	}{
+		{
+			name:      "constant",
+			filter:    Filter{Subtitle: "/in/file.mkv", FrameRate: NewFrameRate(30, 1), ConstantRate: true, InverseTelecine: true},
+			wantGraph: `fieldmatch, decimate, fps=30, subtitles='/in/file.mkv'`,
+			wantMode:  "cfr",
+		},
+		{
+			name:      "variable",
+			filter:    Filter{Subtitle: "/in/file.mkv", FrameRate: NewFrameRate(30000, 1001), VariableRate: true},
+			wantGraph: `subtitles='/in/file.mkv'`,
+			wantMode:  "vfr",
+		},
+		{
+			name:      "keep",
+			filter:    Filter{Subtitle: "/in/file.mkv", FrameRate: NewFrameRate(24000, 1001)},
+			wantGraph: `subtitles='/in/file.mkv'`,
+		},
+	}
+	option := func(args []string, flag string) string {
+		for i, arg := range args {
+			if arg == flag {
+				return args[i+1]
+			}
+		}
+		return ""
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := NewMp4Transcoder("ffmpeg", "/in/file.mkv", "/out", Megabit, tt.filter)
+			for _, args := range [][]string{tr.FirstPass().Args, tr.SecondPass().Args} {
+				if got := option(args, "-filter_complex"); got != tt.wantGraph {
+					t.Errorf("-filter_complex = %v, want %v", got, tt.wantGraph)
+				}
+				if got := option(args, "-fps_mode"); got != tt.wantMode {
+					t.Errorf("-fps_mode = %v, want %v", got, tt.wantMode)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SubtitleStream is a text subtitle stream of the input.
+type SubtitleStream struct {
+	// Map is the stream specifier of the input, e.g. 0:2
+	Map      string
+	Language string
+	Title    string
+	// Default streams are selected by the players
+	Default bool
+}
+
+// Softsub holds the subtitle streams muxed into the outputs, the zero
+// value skips the subtitles.
+//
+// The mp4 outputs hold them as mov_text streams, the HLS outputs as
+// WebVTT renditions, see SubtitleSegments.
+type Softsub struct {
+	Streams []SubtitleStream
+	// Audio is the audio stream of the output, e.g. 0:1. Mapping the
+	// subtitles stops ffmpeg from selecting it, so it is mapped too.
+	// Empty when the input has no audio
+	Audio string
+}
+
+// IsTextSubtitle reports whether the subtitle codec of ffprobe holds
+// text, which can be converted to mov_text and WebVTT. Bitmap subtitles,
+// e.g. hdmv_pgs_subtitle, can only be burned.
+func IsTextSubtitle(codec string) bool {
+	switch codec {
+	case "ass", "ssa", "subrip", "srt", "mov_text", "webvtt", "text":
+		return true
+	}
+	return false
+}
+
+// softsub maps the streams of s to the output with the codec, or skips
+// the subtitles without streams.
+func (t *Transcoder) softsub(s Softsub, codec string) {
+	if len(s.Streams) == 0 {
+		t.SkipSubtitleStream()
+		return
+	}
+	// The unlabelled outputs of the filtergraph are mapped by ffmpeg
+	if !t.hasOption("-filter_complex") {
+		t.Map("0:v:0")
+	}
+	if s.Audio != "" {
+		t.Map(s.Audio)
+	}
+	for _, stream := range s.Streams {
+		t.Map(stream.Map)
+	}
+	t.SubtitleCodec(codec)
+}
+
+// hasOption reports whether an option with the flag is set.
+func (t *Transcoder) hasOption(flag string) bool {
+	for _, option := range t.options {
+		if option.flag == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// SubtitleRendition is a WebVTT rendition of a subtitle stream in the
+// master playlist of an HLS output.
+type SubtitleRendition struct {
+	SubtitleStream
+	// Playlist is the media playlist of the segments, e.g. sub_0.m3u8
+	Playlist string
+}
+
+// Renditions are the WebVTT renditions of the streams.
+func (s Softsub) Renditions() []SubtitleRendition {
+	var renditions []SubtitleRendition
+	for i, stream := range s.Streams {
+		renditions = append(renditions, SubtitleRendition{
+			SubtitleStream: stream,
+			Playlist:       fmt.Sprintf("sub_%d.m3u8", i),
+		})
+	}
+	return renditions
+}
+
+// reference is the name of the video playlist which is written next to
+// the playlist of the rendition, e.g. sub_0_ for sub_0.m3u8. The WebVTT
+// segments are named after it, e.g. sub_0_0.vtt.
+func (r SubtitleRendition) reference() string {
+	return strings.TrimSuffix(r.Playlist, filepath.Ext(r.Playlist)) + "_"
+}
+
+// Scratch are the glob patterns of the files of the copied video, they
+// are only written to cut the segments and can be removed.
+func (r SubtitleRendition) Scratch() []string {
+	ref := r.reference()
+	return []string{ref + ".m3u8", ref + "ref_*.ts"}
+}
+
+// SubtitleSegments builds the command which converts the subtitle stream
+// of the rendition to WebVTT segments, they are listed in the playlist
+// of the rendition in outDir.
+//
+// The hls muxer writes the X-TIMESTAMP-MAP of the segments. It cuts them
+// at the keyframes of the video, so the video of the HLS output is copied
+// along, then the segments are aligned with the segments of the output.
+func SubtitleSegments(executable, input, video, outDir string, r SubtitleRendition, d time.Duration) *exec.Cmd {
+	ref := r.reference()
+
+	var args []string
+	args = append(args, "-y")                            // Overwrite output files without asking.
+	args = append(args, "-loglevel", "repeat+warning")   // Show all warnings and errors.
+	args = append(args, "-progress", "pipe:1")           // Send program-friendly progress information to stdout.
+	args = append(args, "-i", input)                     // Input file url
+	args = append(args, "-i", video)                     // Playlist of the HLS output.
+	args = append(args, "-map", "1:v:0", "-c:v", "copy") // Cut the segments at the keyframes of the output.
+	args = append(args, "-map", r.Map)                   // Select the subtitle stream.
+	args = append(args, "-c:s", "webvtt")                // Convert the subtitle to WebVTT.
+	args = append(args, "-f", "hls")
+	args = append(args, "-hls_time", formatSeconds(d))
+	args = append(args, "-hls_list_size", "0")
+	args = append(args, "-hls_playlist_type", "vod")
+	args = append(args, "-hls_segment_filename", ref+"ref_%03d.ts") // Set the pattern of the copied video.
+	args = append(args, "-hls_subtitle_path", r.Playlist)           // Set the playlist of the WebVTT segments.
+	args = append(args, ref+".m3u8")
+	cmd := exec.Command(executable, args...)
+	cmd.Dir = outDir
+	return cmd
+}
+
+// PeakBitrate measures the peak bitrate of the segments listed in the
+// HLS media playlist, the BANDWIDTH of the variant.
+func PeakBitrate(playlist string) (Bitrate, error) {
+	b, err := ioutil.ReadFile(playlist)
+	if err != nil {
+		return 0, err
+	}
+	var peak Bitrate
+	var duration float64
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value := strings.SplitN(strings.TrimPrefix(line, "#EXTINF:"), ",", 2)[0]
+			if duration, err = strconv.ParseFloat(value, 64); err != nil {
+				return 0, fmt.Errorf("invalid segment duration `%s` in %s", value, playlist)
+			}
+		case line == "" || strings.HasPrefix(line, "#"):
+		default:
+			info, err := os.Stat(filepath.Join(filepath.Dir(playlist), line))
+			if err != nil {
+				return 0, err
+			}
+			if duration > 0 {
+				if r := Bitrate(float64(info.Size()*8) / duration); r > peak {
+					peak = r
+				}
+			}
+			duration = 0
+		}
+	}
+	if peak == 0 {
+		return 0, fmt.Errorf("no segments are listed in %s", playlist)
+	}
+	return peak, nil
+}
+
+// WriteMasterPlaylist writes the master playlist of the variant, e.g.
+// out.m3u8, which references the subtitle renditions. The bandwidth is
+// the peak bitrate of the variant.
+func WriteMasterPlaylist(w io.Writer, variant string, bandwidth Bitrate, renditions []SubtitleRendition) error {
+	// Quoted strings of the playlist can not hold quotes or line breaks
+	quote := func(s string) string {
+		return `"` + strings.NewReplacer(`"`, "'", "\n", " ", "\r", " ").Replace(s) + `"`
+	}
+	yesNo := func(b bool) string {
+		if b {
+			return "YES"
+		}
+		return "NO"
+	}
+
+	lines := []string{"#EXTM3U", "#EXT-X-VERSION:7"}
+	for i, r := range renditions {
+		language := r.Language
+		if language == "" {
+			language = "und"
+		}
+		name := r.Title
+		if name == "" {
+			name = fmt.Sprintf("%s %d", language, i+1)
+		}
+		lines = append(lines, fmt.Sprintf("#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"subs\",NAME=%s,LANGUAGE=%s,DEFAULT=%s,AUTOSELECT=YES,URI=%s",
+			quote(name), quote(language), yesNo(r.Default), quote(r.Playlist)))
+	}
+	inf := fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d", int64(bandwidth))
+	if len(renditions) > 0 {
+		inf += `,SUBTITLES="subs"`
+	}
+	lines = append(lines, inf, variant)
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
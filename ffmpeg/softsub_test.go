@@ -0,0 +1,122 @@
+package ffmpeg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTranscoder_Softsub(t *testing.T) {
+	s := Softsub{
+		Streams: []SubtitleStream{{Map: "0:2", Language: "eng"}, {Map: "0:3", Language: "jpn"}},
+		Audio:   "0:1",
+	}
+	tests := []struct {
+		name   string
+		f      Filter
+		want   string
+		absent []string
+	}{
+		{name: "skipped", f: Filter{Height: 720}, want: "-sn", absent: []string{"-map", "-c:s"}},
+		{name: "muxed", f: Filter{Height: 720, Softsub: s}, want: "-map 0:1 -map 0:2 -map 0:3 -c:s mov_text", absent: []string{"-sn", "0:v:0"}},
+		{name: "muxed without graph", f: Filter{Softsub: s}, want: "-map 0:v:0 -map 0:1 -map 0:2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := NewMp4Transcoder("ffmpeg", "/in/file.mkv", "/out", Megabit, tt.f)
+			if first := strings.Join(tr.FirstPass().Args, " "); strings.Contains(first, "-map") {
+				t.Errorf("first pass maps the streams: %s", first)
+			}
+			got := strings.Join(tr.SecondPass().Args, " ")
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("second pass = %s, want %s", got, tt.want)
+			}
+			for _, a := range tt.absent {
+				if strings.Contains(got, a) {
+					t.Errorf("second pass = %s, want no %s", got, a)
+				}
+			}
+		})
+	}
+}
+
+func TestSubtitleSegments(t *testing.T) {
+	r := Softsub{Streams: []SubtitleStream{{Map: "0:2"}}}.Renditions()[0]
+	cmd := SubtitleSegments("ffmpeg", "/in/file.mkv", "/out/file/out.m3u8", "/out/file", r, HlsSegmentDuration)
+	want := "-i /in/file.mkv -i /out/file/out.m3u8 -map 1:v:0 -c:v copy -map 0:2 -c:s webvtt " +
+		"-f hls -hls_time 10 -hls_list_size 0 -hls_playlist_type vod -hls_segment_filename sub_0_ref_%03d.ts -hls_subtitle_path sub_0.m3u8 sub_0_.m3u8"
+	if got := strings.Join(cmd.Args, " "); !strings.HasSuffix(got, want) || cmd.Dir != "/out/file" {
+		t.Errorf("SubtitleSegments() = %s in %s, want %s", got, cmd.Dir, want)
+	}
+}
+
+func TestSubtitleRendition_Scratch(t *testing.T) {
+	r := Softsub{Streams: []SubtitleStream{{Map: "0:2"}, {Map: "0:3"}}}.Renditions()[1]
+	want := []string{"sub_1_.m3u8", "sub_1_ref_*.ts"}
+	if got := r.Scratch(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Scratch() = %v, want %v", got, want)
+	}
+}
+
+func TestPeakBitrate(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "TestPeakBitrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	playlist := `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:10
+#EXT-X-MAP:URI="init.mp4"
+#EXTINF:10.000000,
+out0.m4s
+#EXTINF:8.000000,
+out1.m4s
+#EXTINF:2.500000,
+out2.m4s
+#EXT-X-ENDLIST
+`
+	files := map[string]int{"out.m3u8": 0, "init.mp4": 1000000, "out0.m4s": 1250000, "out1.m4s": 1500000, "out2.m4s": 250000}
+	for name, size := range files {
+		content := make([]byte, size)
+		if name == "out.m3u8" {
+			content = []byte(playlist)
+		}
+		if err := ioutil.WriteFile(filepath.Join(tmpDir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := PeakBitrate(filepath.Join(tmpDir, "out.m3u8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// out1.m4s holds 12 Mbit in 8 seconds
+	if want := 1500 * Kilobit; got != want {
+		t.Errorf("PeakBitrate() = %s, want %s", got, want)
+	}
+}
+
+func TestWriteMasterPlaylist(t *testing.T) {
+	s := Softsub{Streams: []SubtitleStream{
+		{Map: "0:2", Language: "eng", Title: `Signs "& Songs"`, Default: true},
+		{Map: "0:3"},
+	}}
+	var b strings.Builder
+	if err := WriteMasterPlaylist(&b, "out.m3u8", 1499*Kilobit, s.Renditions()); err != nil {
+		t.Fatal(err)
+	}
+	want := `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="Signs '& Songs'",LANGUAGE="eng",DEFAULT=YES,AUTOSELECT=YES,URI="sub_0.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="und 2",LANGUAGE="und",DEFAULT=NO,AUTOSELECT=YES,URI="sub_1.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=1499000,SUBTITLES="subs"
+out.m3u8
+`
+	if got := b.String(); got != want {
+		t.Errorf("WriteMasterPlaylist() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,86 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"github.com/shiroi-usagi/burner/filtergraph"
+	"strconv"
+	"strings"
+)
+
+// TonemapAlgorithm is the algorithm of the tonemap filter.
+type TonemapAlgorithm string
+
+const (
+	TonemapNone     TonemapAlgorithm = "none"
+	TonemapClip     TonemapAlgorithm = "clip"
+	TonemapLinear   TonemapAlgorithm = "linear"
+	TonemapGamma    TonemapAlgorithm = "gamma"
+	TonemapReinhard TonemapAlgorithm = "reinhard"
+	TonemapHable    TonemapAlgorithm = "hable"
+	TonemapMobius   TonemapAlgorithm = "mobius"
+)
+
+// ParseTonemapAlgorithm parses the name of a tonemap algorithm, e.g. hable.
+func ParseTonemapAlgorithm(s string) (TonemapAlgorithm, error) {
+	switch a := TonemapAlgorithm(strings.ToLower(strings.TrimSpace(s))); a {
+	case TonemapNone, TonemapClip, TonemapLinear, TonemapGamma, TonemapReinhard, TonemapHable, TonemapMobius:
+		return a, nil
+	}
+	return "", fmt.Errorf("unknown tonemap algorithm `%s`", s)
+}
+
+// Tonemap converts HDR frames to SDR, the zero value keeps the frames.
+type Tonemap struct {
+	Algorithm TonemapAlgorithm
+	// Peak overrides the peak brightness of the signal relative to the
+	// nominal 100 nits, zero reads it from the frames
+	Peak float64
+	// Gamut only converts the BT.2020 primaries of SDR frames to BT.709,
+	// the Algorithm and the Peak are not used
+	Gamut bool
+}
+
+// IsZero reports whether the tone mapping is not set.
+func (t Tonemap) IsZero() bool {
+	return t == Tonemap{}
+}
+
+// filters are the filters of the tone mapping. The frames are mapped in
+// linear light, then converted to the BT.709 colors of the output. The
+// gamut conversion only converts the colors.
+func (t Tonemap) filters() []filtergraph.Filter {
+	if t.Gamut {
+		return []filtergraph.Filter{
+			filtergraph.New("zscale").Opt("p", "bt709").Opt("t", "bt709").Opt("m", "bt709").Opt("r", "tv"),
+			filtergraph.New("format").Arg("yuv420p"),
+		}
+	}
+	tonemap := filtergraph.New("tonemap").Opt("tonemap", string(t.Algorithm)).Opt("desat", "0")
+	if t.Peak > 0 {
+		tonemap = tonemap.Opt("peak", strconv.FormatFloat(t.Peak, 'f', -1, 64))
+	}
+	return []filtergraph.Filter{
+		filtergraph.New("zscale").Opt("t", "linear").Opt("npl", "100"),
+		filtergraph.New("format").Arg("gbrpf32le"),
+		filtergraph.New("zscale").Opt("p", "bt709"),
+		tonemap,
+		filtergraph.New("zscale").Opt("t", "bt709").Opt("m", "bt709").Opt("r", "tv"),
+		filtergraph.New("format").Arg("yuv420p"),
+	}
+}
+
+// IsHDR reports whether the probed color transfer of a video stream is
+// the PQ or HLG transfer of HDR, which is tone mapped for SDR outputs.
+func IsHDR(transfer string) bool {
+	switch transfer {
+	case "smpte2084", "arib-std-b67":
+		return true
+	}
+	return false
+}
+
+// IsWideGamut reports whether a video stream is SDR with the BT.2020
+// primaries, whose colors are converted to BT.709 without tone mapping.
+func IsWideGamut(transfer, primaries string) bool {
+	return primaries == "bt2020" && !IsHDR(transfer)
+}
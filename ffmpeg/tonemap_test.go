@@ -0,0 +1,64 @@
+package ffmpeg
+
+import (
+	"testing"
+)
+
+func TestTonemap_Graph(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   string
+	}{
+		{
+			name:   "before the subtitle",
+			filter: Filter{Subtitle: "/in/file.mkv", Crop: Crop{Width: 3840, Height: 1600, Y: 280}, Tonemap: Tonemap{Algorithm: TonemapHable}},
+			want: `crop=3840:1600:0:280, zscale=t=linear:npl=100, format=gbrpf32le, zscale=p=bt709, ` +
+				`tonemap=tonemap=hable:desat=0, zscale=t=bt709:m=bt709:r=tv, format=yuv420p, subtitles='/in/file.mkv'`,
+		},
+		{
+			name:   "peak",
+			filter: Filter{Tonemap: Tonemap{Algorithm: TonemapMobius, Peak: 10}},
+			want: `zscale=t=linear:npl=100, format=gbrpf32le, zscale=p=bt709, ` +
+				`tonemap=tonemap=mobius:desat=0:peak=10, zscale=t=bt709:m=bt709:r=tv, format=yuv420p`,
+		},
+		{
+			name:   "gamut",
+			filter: Filter{Subtitle: "/in/file.mkv", Tonemap: Tonemap{Gamut: true}},
+			want:   `zscale=p=bt709:t=bt709:m=bt709:r=tv, format=yuv420p, subtitles='/in/file.mkv'`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.String(); got != tt.want {
+				t.Errorf("String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsHDR(t *testing.T) {
+	tests := []struct {
+		transfer  string
+		primaries string
+		hdr       bool
+		wide      bool
+	}{
+		{transfer: "smpte2084", primaries: "bt2020", hdr: true},
+		{transfer: "arib-std-b67", primaries: "bt2020", hdr: true},
+		{transfer: "bt2020-10", primaries: "bt2020", wide: true},
+		{transfer: "bt709", primaries: "bt2020", wide: true},
+		{transfer: "bt709", primaries: "bt709"},
+		{},
+	}
+	for _, tt := range tests {
+		t.Run(tt.transfer+"/"+tt.primaries, func(t *testing.T) {
+			if got := IsHDR(tt.transfer); got != tt.hdr {
+				t.Errorf("IsHDR() = %v, want %v", got, tt.hdr)
+			}
+			if got := IsWideGamut(tt.transfer, tt.primaries); got != tt.wide {
+				t.Errorf("IsWideGamut() = %v, want %v", got, tt.wide)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package ffmpeg
 
 import (
 	"fmt"
+	"github.com/shiroi-usagi/burner/filtergraph"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -28,11 +29,17 @@ type Transcoder struct {
 	outFile    string
 	outDir     string
 
-	options []ffmpegOption
+	inputOptions []ffmpegOption
+	options      []ffmpegOption
+	// The arguments of the inputs read after the input, e.g. the watermark
+	extraInputs [][]string
+
+	// The part of the input in the output, set by the seek options and Duration
+	start, length time.Duration
 }
 
 // NewTranscoder builds a Transcoder for fragmented mp4 with preset data
-func NewTranscoder(executable, input, outDir, bitrate string, f Filter) *Transcoder {
+func NewTranscoder(executable, input, outDir string, bitrate Bitrate, f Filter) *Transcoder {
 	t := Transcoder{
 		executable: executable,
 
@@ -40,13 +47,16 @@ func NewTranscoder(executable, input, outDir, bitrate string, f Filter) *Transco
 		outFile: filepath.Base(input),
 		outDir:  outDir,
 	}
-	t.VideoBitrate(bitrate)
+	t.VideoBitrate(bitrate.String())
 	t.Tune("animation")
 	t.Preset("medium")
 	t.PixelFormat("yuv420p")
-	// Disable subtitle burning in this preset
+	// Disable subtitle burning and the bumpers in this preset
 	f.Subtitle = ""
-	t.Filter(f)
+	f.Intro, f.Outro = nil, nil
+	// Every audio stream is kept, the loudness is measured on one
+	f.Loudnorm = Loudnorm{}
+	t.filter(f)
 	t.AudioCodec("aac")
 	t.AudioBitrate("128k")
 	t.SubtitleCodec("copy")
@@ -60,8 +70,11 @@ func NewTranscoder(executable, input, outDir, bitrate string, f Filter) *Transco
 	return &t
 }
 
+// HlsSegmentDuration is the duration of the segments of the HLS outputs.
+const HlsSegmentDuration = 10 * time.Second
+
 // NewFragmentedMp4Transcoder builds a Transcoder for fragmented mp4 with preset data
-func NewFragmentedMp4Transcoder(executable, input, outDir, bitrate string, f Filter) *Transcoder {
+func NewFragmentedMp4Transcoder(executable, input, outDir string, bitrate Bitrate, f Filter) *Transcoder {
 	t := Transcoder{
 		executable: executable,
 
@@ -70,16 +83,16 @@ func NewFragmentedMp4Transcoder(executable, input, outDir, bitrate string, f Fil
 		outDir:  filepath.Join(outDir, filename(input)),
 	}
 	t.VideoCodec("libx264")
-	t.VideoBitrate(bitrate)
+	t.VideoBitrate(bitrate.String())
 	t.Tune("animation")
 	t.Preset("medium")
 	t.PixelFormat("yuv420p")
-	t.Filter(f)
+	t.filter(f)
 	t.AudioCodec("aac")
 	t.AudioBitrate("128k")
 	t.AudioChannels("2")
 	t.HlsFlags("append_list")
-	t.HlsTime(10 * time.Second)
+	t.HlsTime(HlsSegmentDuration)
 	t.HlsListSize(0)
 	t.HlsSegmentType("fmp4")
 	t.SkipSubtitleStream()
@@ -87,7 +100,7 @@ func NewFragmentedMp4Transcoder(executable, input, outDir, bitrate string, f Fil
 }
 
 // NewMp4Transcoder builds a Transcoder for mp4 with preset data
-func NewMp4Transcoder(executable, input, outDir, bitrate string, f Filter) *Transcoder {
+func NewMp4Transcoder(executable, input, outDir string, bitrate Bitrate, f Filter) *Transcoder {
 	t := Transcoder{
 		executable: executable,
 
@@ -96,20 +109,20 @@ func NewMp4Transcoder(executable, input, outDir, bitrate string, f Filter) *Tran
 		outDir:  outDir,
 	}
 	t.VideoCodec("libx264")
-	t.VideoBitrate(bitrate)
+	t.VideoBitrate(bitrate.String())
 	t.Tune("animation")
 	t.Preset("medium")
 	t.PixelFormat("yuv420p")
-	t.Filter(f)
+	t.filter(f)
 	t.AudioCodec("aac")
 	t.AudioBitrate("128k")
 	t.AudioChannels("2")
-	t.SkipSubtitleStream()
+	t.softsub(f.Softsub, "mov_text")
 	return &t
 }
 
 // NewSampleMp4Transcoder builds a Transcoder for fragmented mp4 with preset data
-func NewSampleMp4Transcoder(executable, input, outDir, bitrate string, f Filter) *Transcoder {
+func NewSampleMp4Transcoder(executable, input, outDir string, bitrate Bitrate, f Filter) *Transcoder {
 	t := Transcoder{
 		executable: executable,
 
@@ -120,11 +133,61 @@ func NewSampleMp4Transcoder(executable, input, outDir, bitrate string, f Filter)
 	t.Seek(time.Minute)
 	t.Duration(time.Minute)
 	t.VideoCodec("libx264")
-	t.VideoBitrate(bitrate)
+	t.VideoBitrate(bitrate.String())
+	t.Tune("animation")
+	t.Preset("medium")
+	t.PixelFormat("yuv420p")
+	// The sample only holds a part of the input
+	f.Intro, f.Outro = nil, nil
+	t.filter(f)
+	t.AudioCodec("aac")
+	t.AudioBitrate("128k")
+	t.AudioChannels("2")
+	t.SkipSubtitleStream()
+	return &t
+}
+
+// NewVideoChunkTranscoder builds a Transcoder for a video only mp4 which
+// holds the part of the input starting at start with the given length.
+//
+// The chunks of an input are meant to be joined with Concat.
+func NewVideoChunkTranscoder(executable, input, outFile, outDir string, bitrate Bitrate, f Filter, start, length time.Duration) *Transcoder {
+	t := Transcoder{
+		executable: executable,
+
+		input:   input,
+		outFile: outFile,
+		outDir:  outDir,
+	}
+	t.InputSeek(start)
+	t.Duration(length)
+	t.VideoCodec("libx264")
+	t.VideoBitrate(bitrate.String())
 	t.Tune("animation")
 	t.Preset("medium")
 	t.PixelFormat("yuv420p")
-	t.Filter(f)
+	// Subtitles are rendered with the timestamps of the whole input
+	f.Offset = start
+	// A chunk only holds a part of the input
+	f.Intro, f.Outro = nil, nil
+	// The audio is encoded apart
+	f.Loudnorm = Loudnorm{}
+	t.filter(f)
+	t.SkipAudioStream()
+	t.SkipSubtitleStream()
+	return &t
+}
+
+// NewAudioTranscoder builds a Transcoder for the audio of the input with preset data
+func NewAudioTranscoder(executable, input, outFile, outDir string) *Transcoder {
+	t := Transcoder{
+		executable: executable,
+
+		input:   input,
+		outFile: outFile,
+		outDir:  outDir,
+	}
+	t.SkipVideoStream()
 	t.AudioCodec("aac")
 	t.AudioBitrate("128k")
 	t.AudioChannels("2")
@@ -132,18 +195,69 @@ func NewSampleMp4Transcoder(executable, input, outDir, bitrate string, f Filter)
 	return &t
 }
 
-// AudioChannels downmux the output channels to the specified value.
+// AudioChannels downmux the output channels to the specified value,
+// it replaces the channels of the preset.
 func (t *Transcoder) AudioChannels(c string) {
-	t.options = append(t.options, ffmpegOption{
+	t.setOption(ffmpegOption{
 		firstPass: false, secondPass: true, flag: "-ac", value: c,
 	})
 }
 
+// AudioSampleRate sets the sample rate for all audio streams in Hz, it
+// replaces the sample rate of the preset.
+func (t *Transcoder) AudioSampleRate(r string) {
+	t.setOption(ffmpegOption{
+		firstPass: false, secondPass: true, flag: "-ar", value: r,
+	})
+}
+
+// AudioCopy copies the audio streams without encoding, the options of
+// the audio encoding are removed.
+func (t *Transcoder) AudioCopy() {
+	t.AudioCodec(AudioCodecCopy)
+	for _, flag := range []string{"-b:a", "-ac", "-ar", "-af"} {
+		t.removeOption(flag)
+	}
+}
+
+// setOption replaces the option with the same flag, or appends it.
+func (t *Transcoder) setOption(o ffmpegOption) {
+	for i, option := range t.options {
+		if option.flag == o.flag {
+			t.options[i] = o
+			return
+		}
+	}
+	t.options = append(t.options, o)
+}
+
+// removeOption removes the options with the flag.
+func (t *Transcoder) removeOption(flag string) {
+	var options []ffmpegOption
+	for _, option := range t.options {
+		if option.flag != flag {
+			options = append(options, option)
+		}
+	}
+	t.options = options
+}
+
 // OutDir is the target directory of the output
 func (t *Transcoder) OutDir() string {
 	return t.outDir
 }
 
+// Output is the path of the output file
+func (t *Transcoder) Output() string {
+	return filepath.Join(t.outDir, t.outFile)
+}
+
+// Window is the part of the input in the output, zero length means
+// the whole input.
+func (t *Transcoder) Window() (start, length time.Duration) {
+	return t.start, t.length
+}
+
 // VideoCodec sets the codec for all video streams
 func (t *Transcoder) VideoCodec(c string) {
 	t.options = append(t.options, ffmpegOption{
@@ -151,6 +265,36 @@ func (t *Transcoder) VideoCodec(c string) {
 	})
 }
 
+// VideoCopy copies the video streams without encoding, the options of
+// the video encoding and the filters are removed. The video is mapped
+// when the streams of the output are mapped by the preset.
+func (t *Transcoder) VideoCopy() {
+	for _, flag := range []string{"-b:v", "-tune", "-preset", "-pix_fmt", "-filter_complex", "-fps_mode"} {
+		t.removeOption(flag)
+	}
+	t.extraInputs = nil
+	t.setOption(ffmpegOption{
+		firstPass: true, secondPass: true, flag: "-c:v", value: "copy",
+	})
+	if t.hasOption("-map") {
+		// The video is the first stream of the output
+		t.options = append([]ffmpegOption{{
+			firstPass: false, secondPass: true, flag: "-map", value: "0:v",
+		}}, t.options...)
+	}
+}
+
+// Remux reports whether the video is copied, the streams are remuxed by
+// the SinglePass without encoding the video.
+func (t Transcoder) Remux() bool {
+	for _, option := range t.options {
+		if option.flag == "-c:v" {
+			return option.value == "copy"
+		}
+	}
+	return false
+}
+
 // VideoBitrate sets the bitrate for all video streams
 func (t *Transcoder) VideoBitrate(b string) {
 	t.options = append(t.options, ffmpegOption{
@@ -179,22 +323,92 @@ func (t *Transcoder) PixelFormat(pf string) {
 	})
 }
 
-func (t *Transcoder) Filter(f Filter) {
+// ImageInput adds a still image as the next input, it is looped so it
+// lasts as long as the input
+func (t *Transcoder) ImageInput(path string) {
+	t.extraInputs = append(t.extraInputs, []string{"-loop", "1", "-i", path})
+}
+
+// AppendInput adds a file as the next input
+func (t *Transcoder) AppendInput(path string) {
+	t.extraInputs = append(t.extraInputs, []string{"-i", path})
+}
+
+// filter sets the filtergraph of f with the inputs it reads and the
+// frame rate mode of its output
+func (t *Transcoder) filter(f Filter) {
+	switch {
+	case f.ConstantRate && !f.FrameRate.IsZero():
+		t.FpsMode("cfr")
+	case f.VariableRate:
+		t.FpsMode("vfr")
+	}
+	if f.Watermark.Image != "" {
+		t.ImageInput(f.Watermark.Image)
+	}
+	for _, b := range f.Intro {
+		t.AppendInput(b.Path)
+	}
+	for _, b := range f.Outro {
+		t.AppendInput(b.Path)
+	}
+	if !f.HasBumpers() || f.AudioInput == "" {
+		t.Filter(f.Graph())
+		t.AudioFilter(f.AudioFilters()...)
+		return
+	}
+	// The first pass skips the audio, so its graph has no audio output
+	video := f
+	video.AudioInput = ""
+	t.passFilter(video.Graph(), true, false)
+	t.passFilter(f.Graph(), false, true)
+}
+
+// Filter sets the filtergraph of the encoding, an empty graph is skipped
+func (t *Transcoder) Filter(g filtergraph.Graph) {
+	t.passFilter(g, true, true)
+}
+
+func (t *Transcoder) passFilter(g filtergraph.Graph, firstPass, secondPass bool) {
+	if g.IsEmpty() {
+		return
+	}
 	t.options = append(t.options, ffmpegOption{
-		firstPass: true, secondPass: true, flag: "-filter_complex", value: f.String(),
+		firstPass: firstPass, secondPass: secondPass, flag: "-filter_complex", value: g.String(),
 	})
 }
 
-// AudioCodec sets the codec for all audio streams
-func (t *Transcoder) AudioCodec(c string) {
+// FpsMode sets the frame rate mode of the output, e.g. cfr duplicates
+// and drops frames to keep a constant rate, vfr keeps the timestamps
+func (t *Transcoder) FpsMode(m string) {
 	t.options = append(t.options, ffmpegOption{
+		firstPass: true, secondPass: true, flag: "-fps_mode", value: m,
+	})
+}
+
+// AudioFilter sets the filters of the audio streams, it is skipped by
+// the first pass
+func (t *Transcoder) AudioFilter(filters ...filtergraph.Filter) {
+	if len(filters) == 0 {
+		return
+	}
+	t.setOption(ffmpegOption{
+		firstPass: false, secondPass: true, flag: "-af", value: filtergraph.NewChain().Add(0, filters...).String(),
+	})
+}
+
+// AudioCodec sets the codec for all audio streams, it replaces the
+// codec of the preset
+func (t *Transcoder) AudioCodec(c string) {
+	t.setOption(ffmpegOption{
 		firstPass: false, secondPass: true, flag: "-c:a", value: c,
 	})
 }
 
-// AudioBitrate sets the bitrate for all audio streams
+// AudioBitrate sets the bitrate for all audio streams, it replaces
+// the bitrate of the preset
 func (t *Transcoder) AudioBitrate(b string) {
-	t.options = append(t.options, ffmpegOption{
+	t.setOption(ffmpegOption{
 		firstPass: false, secondPass: true, flag: "-b:a", value: b,
 	})
 }
@@ -213,6 +427,28 @@ func (t *Transcoder) SkipSubtitleStream() {
 	})
 }
 
+// PassLogFile sets the prefix of the two-pass log files, ffmpeg appends
+// the index of the stream and `.log` to it. Default value is `ffmpeg2pass`.
+func (t *Transcoder) PassLogFile(prefix string) {
+	t.options = append(t.options, ffmpegOption{
+		firstPass: true, secondPass: true, flag: "-passlogfile", value: prefix,
+	})
+}
+
+// SkipAudioStream sets a flag to skip inclusion of audio streams
+func (t *Transcoder) SkipAudioStream() {
+	t.options = append(t.options, ffmpegOption{
+		firstPass: false, secondPass: true, flag: "-an",
+	})
+}
+
+// SkipVideoStream sets a flag to skip inclusion of video streams
+func (t *Transcoder) SkipVideoStream() {
+	t.options = append(t.options, ffmpegOption{
+		firstPass: false, secondPass: true, flag: "-vn",
+	})
+}
+
 // Map maps specific streams to the target file
 func (t *Transcoder) Map(v string) {
 	t.options = append(t.options, ffmpegOption{
@@ -291,10 +527,12 @@ func (t *Transcoder) AttachmentCopy() {
 // available where x is the required width.
 //
 // ```
-// ffmpeg -i sample.mpeg \
-//   -f hls -hls_time 3 -hls_list_size 5 \
-//   -hls_flags second_level_segment_index+second_level_segment_size+second_level_segment_duration \
-//   -strftime 1 -strftime_mkdir 1 -hls_segment_filename "segment_%Y%m%d%H%M%S_%%04d_%%08s_%%013t.ts" stream.m3u8
+//
+//	ffmpeg -i sample.mpeg \
+//	  -f hls -hls_time 3 -hls_list_size 5 \
+//	  -hls_flags second_level_segment_index+second_level_segment_size+second_level_segment_duration \
+//	  -strftime 1 -strftime_mkdir 1 -hls_segment_filename "segment_%Y%m%d%H%M%S_%%04d_%%08s_%%013t.ts" stream.m3u8
+//
 // ```
 // This will produce segments like this: segment_20170102194334_0003_00122200_0000003000000.ts,
 // segment_20170102194334_0004_00120072_0000003000000.ts etc.
@@ -358,6 +596,7 @@ func (t *Transcoder) HlsSegmentType(st string) {
 //
 // When used as an output option (before an output url), decodes but discards input until the timestamps reach position.
 func (t *Transcoder) Seek(p time.Duration) {
+	t.start = p
 	unix := time.Unix(0, 0).Add(p).UTC()
 
 	t.options = append(t.options, ffmpegOption{
@@ -365,16 +604,27 @@ func (t *Transcoder) Seek(p time.Duration) {
 	})
 }
 
+// InputSeek sets the `-ss` option of the input
+//
+// Unlike Seek, the input is not decoded until the position. The timestamps
+// of the input are shifted, so the position becomes the start of the output.
+func (t *Transcoder) InputSeek(p time.Duration) {
+	t.start = p
+	t.inputOptions = append(t.inputOptions, ffmpegOption{
+		firstPass: true, secondPass: true, flag: "-ss", value: formatSeconds(p),
+	})
+}
+
 // Duration sets the `-t` option for the encoding
 //
 // When used as an input option (before `-i`), limit the duration of data read from the input file.
 //
 // When used as an output option (before an output url), stop writing the output after its duration reaches duration.
 func (t *Transcoder) Duration(d time.Duration) {
-	unix := time.Unix(0, 0).Add(d).UTC()
-
+	t.length = d
+	// The chunks are cut at keyframes, the fraction of the second is kept
 	t.options = append(t.options, ffmpegOption{
-		firstPass: true, secondPass: true, flag: "-t", value: unix.Format("15:04:05"),
+		firstPass: true, secondPass: true, flag: "-t", value: formatSeconds(d),
 	})
 }
 
@@ -383,18 +633,11 @@ func (t Transcoder) FirstPass() *exec.Cmd {
 	args = append(args, "-y")                          // Overwrite output files without asking.
 	args = append(args, "-loglevel", "repeat+warning") // Show all warnings and errors.
 	args = append(args, "-progress", "pipe:1")         // Send program-friendly progress information to stdout.
-	args = append(args, "-i", t.input)                 // Input file url.
-	args = append(args, "-pass", "1")                  // Select the pass number 1.
-	for _, option := range t.options {
-		if !option.firstPass {
-			continue
-		}
-		if option.value == "" {
-			args = append(args, option.flag)
-		} else {
-			args = append(args, option.flag, option.value)
-		}
-	}
+	args = appendOptions(args, t.inputOptions, true)
+	args = append(args, "-i", t.input) // Input file url.
+	args = appendInputs(args, t.extraInputs)
+	args = append(args, "-pass", "1") // Select the pass number 1.
+	args = appendOptions(args, t.options, true)
 	args = append(args, "-an")       // Skip inclusion of audio.
 	args = append(args, "-f", "mp4") // Force output file format.
 	args = append(args, os.DevNull)  // Set output to null.
@@ -407,10 +650,64 @@ func (t Transcoder) SecondPass() *exec.Cmd {
 	var args []string
 	args = append(args, "-loglevel", "repeat+warning") // Show all warnings and errors.
 	args = append(args, "-progress", "pipe:1")         // Send program-friendly progress information to stdout.
-	args = append(args, "-i", t.input)                 // Input file url
-	args = append(args, "-pass", "2")                  // Select the pass number 2
-	for _, option := range t.options {
-		if !option.secondPass {
+	args = appendOptions(args, t.inputOptions, false)
+	args = append(args, "-i", t.input) // Input file url
+	args = appendInputs(args, t.extraInputs)
+	args = append(args, "-pass", "2") // Select the pass number 2
+	args = appendOptions(args, t.options, false)
+	args = append(args, t.outFile) // Set output file.
+	cmd := exec.Command(t.executable, args...)
+	cmd.Dir = t.outDir
+	return cmd
+}
+
+// SinglePass builds the command of an encoding without two-pass, it
+// uses the options of the second pass.
+func (t Transcoder) SinglePass() *exec.Cmd {
+	var args []string
+	args = append(args, "-y")                          // Overwrite output files without asking.
+	args = append(args, "-loglevel", "repeat+warning") // Show all warnings and errors.
+	args = append(args, "-progress", "pipe:1")         // Send program-friendly progress information to stdout.
+	args = appendOptions(args, t.inputOptions, false)
+	args = append(args, "-i", t.input) // Input file url
+	args = appendInputs(args, t.extraInputs)
+	args = appendOptions(args, t.options, false)
+	args = append(args, t.outFile) // Set output file.
+	cmd := exec.Command(t.executable, args...)
+	cmd.Dir = t.outDir
+	return cmd
+}
+
+// Concat builds the command which joins the video files listed in the
+// concat demuxer list with the audio file without encoding.
+func Concat(executable, list, audio, output string) *exec.Cmd {
+	var args []string
+	args = append(args, "-y")                          // Overwrite output files without asking.
+	args = append(args, "-loglevel", "repeat+warning") // Show all warnings and errors.
+	args = append(args, "-progress", "pipe:1")         // Send program-friendly progress information to stdout.
+	args = append(args, "-f", "concat", "-safe", "0")  // Read the input as a list of files, allow absolute paths.
+	args = append(args, "-i", list)                    // Input file url of the list.
+	args = append(args, "-i", audio)                   // Input file url of the audio.
+	args = append(args, "-map", "0:v", "-map", "1:a")  // Video from the list, audio from the audio file.
+	args = append(args, "-c", "copy")                  // Copy the streams without encoding.
+	args = append(args, output)                        // Set output file.
+	cmd := exec.Command(executable, args...)
+	cmd.Dir = filepath.Dir(output)
+	return cmd
+}
+
+// appendInputs appends the arguments of the extra inputs to args.
+func appendInputs(args []string, inputs [][]string) []string {
+	for _, input := range inputs {
+		args = append(args, input...)
+	}
+	return args
+}
+
+// appendOptions appends the options of the given pass to args.
+func appendOptions(args []string, options []ffmpegOption, firstPass bool) []string {
+	for _, option := range options {
+		if firstPass && !option.firstPass || !firstPass && !option.secondPass {
 			continue
 		}
 		if option.value == "" {
@@ -419,60 +716,180 @@ func (t Transcoder) SecondPass() *exec.Cmd {
 			args = append(args, option.flag, option.value)
 		}
 	}
-	args = append(args, t.outFile) // Set output file.
-	cmd := exec.Command(t.executable, args...)
-	cmd.Dir = t.outDir
-	return cmd
+	return args
+}
+
+// formatSeconds formats the duration as seconds for ffmpeg.
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
 }
 
 type Filter struct {
 	// Source file for subtitle
 	Subtitle string
+	// ASS style overrides of the subtitle, e.g. FontName=Arial
+	ForceStyle string
+	// SubtitleAfterScale renders the subtitle on the scaled frames,
+	// the text is sharper on downscaled outputs
+	SubtitleAfterScale bool
 	// Width value of the scale filter
 	Width int
 	// Height value of the scale filter
 	Height int
 	// Enable/disable upscaling in scale filter with use of min
 	Upscaling bool
-}
+	// Offset of the input in the source of the subtitle, for
+	// inputs which start later than the subtitle, e.g. chunks
+	Offset time.Duration
+	// Crop removes the black bars before the subtitle is rendered
+	Crop Crop
+	// Deinterlacer deinterlaces the frames, or only the frames left
+	// combed by the inverse telecine
+	Deinterlacer Deinterlacer
+	// InverseTelecine restores the progressive frames of telecined inputs
+	InverseTelecine bool
+	// Tonemap converts HDR frames to SDR before the subtitle is rendered
+	Tonemap Tonemap
+	// Watermark is drawn over the scaled frames, it is read from the
+	// second input of the command
+	Watermark Watermark
+	// Intro and Outro are joined before and after the input, they are
+	// read from the inputs of the command after the watermark
+	Intro []Bumper
+	Outro []Bumper
+	// AudioInput is the audio stream joined with the bumpers, e.g. 0:a:0,
+	// empty joins the video only
+	AudioInput string
+	// FrameRate of the output, the bumpers are converted to it
+	FrameRate FrameRate
+	// ConstantRate converts the frames of the input to the FrameRate
+	ConstantRate bool
+	// VariableRate keeps the timestamps of variable frame rate inputs
+	VariableRate bool
+	// Downmix is the matrix of the pan filter applied to the audio, e.g.
+	// stereo|FL=FC+0.30*FL+0.30*BL|FR=FC+0.30*FR+0.30*BR
+	Downmix string
+	// Loudnorm normalizes the audio in the second pass, in the graph
+	// when the audio is joined with the bumpers
+	Loudnorm Loudnorm
+	// Softsub are the subtitle streams muxed into the mp4 output
+	Softsub Softsub
+}
+
+// The stages of the video chain, the filters of a chain are ordered by
+// their stage.
+const (
+	// StageTimestamps shifts the timestamps to the time of the subtitle.
+	StageTimestamps = iota * 10
+	// StageDeinterlace restores the progressive frames.
+	StageDeinterlace
+	// StageRate converts the frames to a constant rate.
+	StageRate
+	// StageCrop removes the black bars, the subtitle is not cut.
+	StageCrop
+	// StageTonemap maps HDR to SDR, the colors of the subtitle are kept.
+	StageTonemap
+	// StageSubtitle renders the subtitle on the frames of the source.
+	StageSubtitle
+	// StageScale resizes the frames.
+	StageScale
+	// StageSubtitleScaled renders the subtitle on the scaled frames.
+	StageSubtitleScaled
+	// StageTimestampsReset restores the timestamps of the input.
+	StageTimestampsReset
+	// StageConcat prepares the frames to be joined with the bumpers.
+	StageConcat
+)
 
-func (f Filter) String() string {
-	var filters []string
+// Chain builds the video chain of the filter.
+func (f Filter) Chain() *filtergraph.Chain {
+	c := filtergraph.NewChain()
+	if f.Offset != 0 {
+		c.Add(StageTimestamps, filtergraph.New("setpts").Arg(fmt.Sprintf("PTS+%s/TB", formatSeconds(f.Offset))))
+		c.Add(StageTimestampsReset, filtergraph.New("setpts").Arg("PTS-STARTPTS"))
+	}
+	switch {
+	case f.InverseTelecine && f.Deinterlacer != DeinterlacerNone:
+		// Only the frames left combed by the field matching are deinterlaced
+		c.Add(StageDeinterlace,
+			filtergraph.New("fieldmatch"),
+			filtergraph.New(string(f.Deinterlacer)).Opt("deint", "interlaced"),
+			filtergraph.New("decimate"),
+		)
+	case f.InverseTelecine:
+		c.Add(StageDeinterlace, filtergraph.New("fieldmatch"), filtergraph.New("decimate"))
+	case f.Deinterlacer != DeinterlacerNone:
+		c.Add(StageDeinterlace, filtergraph.New(string(f.Deinterlacer)))
+	}
+	if f.ConstantRate && !f.FrameRate.IsZero() {
+		c.Add(StageRate, filtergraph.New("fps").Arg(f.FrameRate.String()))
+	}
+	if !f.Crop.IsZero() {
+		c.Add(StageCrop, filtergraph.New("crop").
+			Arg(strconv.Itoa(f.Crop.Width)).Arg(strconv.Itoa(f.Crop.Height)).
+			Arg(strconv.Itoa(f.Crop.X)).Arg(strconv.Itoa(f.Crop.Y)))
+	}
+	if !f.Tonemap.IsZero() {
+		c.Add(StageTonemap, f.Tonemap.filters()...)
+	}
 	if f.Subtitle != "" {
-		// path must be escaped for -vf and -filter_complex
-		p := f.Subtitle
-		p = strings.ReplaceAll(p, `\`, `\\`)
-		p = strings.ReplaceAll(p, `:`, `\:`)
-		filters = append(filters, fmt.Sprintf(`subtitles='%s'`, p))
+		sub := filtergraph.New("subtitles").QuotedArg(f.Subtitle)
+		if f.ForceStyle != "" {
+			sub = sub.QuotedOpt("force_style", f.ForceStyle)
+		}
+		stage := StageSubtitle
+		if f.SubtitleAfterScale {
+			stage = StageSubtitleScaled
+		}
+		c.Add(stage, sub)
 	}
 	if f.Width != 0 || f.Height != 0 {
-		if f.Upscaling {
-			filters = append(filters, fmt.Sprintf("scale=%d:%d", f.Width, f.Height))
-		} else {
-			filters = append(filters, fmt.Sprintf("scale='min(%d,iw)':'min(%d,ih)'", f.Width, f.Height))
+		w, h := strconv.Itoa(f.Width), strconv.Itoa(f.Height)
+		if !f.Upscaling {
+			w, h = fmt.Sprintf("min(%s,iw)", w), fmt.Sprintf("min(%s,ih)", h)
 		}
+		c.Add(StageScale, filtergraph.New("scale").Arg(w).Arg(h))
+	}
+	return c
+}
+
+// Graph builds the filtergraph of the filter.
+func (f Filter) Graph() filtergraph.Graph {
+	var g filtergraph.Graph
+	main, next := f.Chain(), 1
+	if f.Watermark.Image != "" {
+		g.Add(main.Input("0:v").Output("main"))
+		main = f.Watermark.overlay(&g, "main", strconv.Itoa(next)+":v", f.Offset)
+		next++
+	} else if f.HasBumpers() {
+		main.Input("0:v")
+	}
+	if !f.HasBumpers() {
+		g.Add(main)
+		return g
 	}
-	return strings.Join(filters, ", ")
+	f.concat(&g, main, next)
+	return g
 }
 
-func BitrateToKilobit(bitrate string) int64 {
-	switch {
-	case strings.HasSuffix(bitrate, "k"):
-		i, err := strconv.ParseInt(bitrate[:len(bitrate)-1], 10, 64)
-		if err != nil {
-			panic(err)
-		}
-		return i
-	case strings.HasSuffix(bitrate, "M"):
-		i, err := strconv.ParseInt(bitrate[:len(bitrate)-1], 10, 64)
-		if err != nil {
-			panic(err)
-		}
-		return i * 1024
+// AudioFilters are the filters of the audio, the downmix and the
+// loudness normalization.
+func (f Filter) AudioFilters() []filtergraph.Filter {
+	var filters []filtergraph.Filter
+	if f.Downmix != "" {
+		filters = append(filters, filtergraph.New("pan").Arg(f.Downmix))
+	}
+	if !f.Loudnorm.IsZero() {
+		filters = append(filters, f.Loudnorm.filters()...)
 	}
-	return 0
+	return filters
 }
 
-func KilobitToBitrate(kilobit int64) string {
-	return fmt.Sprintf("%dk", kilobit)
+// HasBumpers reports whether clips are joined with the input.
+func (f Filter) HasBumpers() bool {
+	return len(f.Intro) > 0 || len(f.Outro) > 0
+}
+
+func (f Filter) String() string {
+	return f.Graph().String()
 }
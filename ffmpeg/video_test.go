@@ -1,15 +1,24 @@
 package ffmpeg
 
 import (
+	"os/exec"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestFilter_String(t *testing.T) {
 	type fields struct {
-		subtitle  string
-		width     int
-		height    int
-		upscaling bool
+		subtitle   string
+		forceStyle string
+		width      int
+		height     int
+		upscaling  bool
+		offset     time.Duration
+		crop       Crop
+		deint      Deinterlacer
+		ivtc       bool
+		afterScale bool
 	}
 	tests := []struct {
 		name   string
@@ -26,6 +35,16 @@ func TestFilter_String(t *testing.T) {
 			fields: fields{subtitle: `C:\in\file.mkv`},
 			want:   `subtitles='C\:\\in\\file.mkv'`,
 		},
+		{
+			name:   "subtitle with force style",
+			fields: fields{subtitle: `/in/file.mkv`, forceStyle: `FontName=Arial`},
+			want:   `subtitles='/in/file.mkv':force_style='FontName=Arial'`,
+		},
+		{
+			name:   "subtitle with quote and comma",
+			fields: fields{subtitle: `/in/it's, here.mkv`, forceStyle: `FontName=Arial,Fontsize=24`},
+			want:   `subtitles='/in/it\'\''s, here.mkv':force_style='FontName=Arial,Fontsize=24'`,
+		},
 		{
 			name:   "scale",
 			fields: fields{width: -1, height: 720, upscaling: true},
@@ -41,14 +60,52 @@ func TestFilter_String(t *testing.T) {
 			fields: fields{subtitle: `/in/file.mkv`, width: 320, height: 240, upscaling: true},
 			want:   `subtitles='/in/file.mkv', scale=320:240`,
 		},
+		{
+			name:   "crop",
+			fields: fields{subtitle: `/in/file.mkv`, width: 320, height: 240, upscaling: true, crop: Crop{Width: 1920, Height: 800, Y: 140}},
+			want:   `crop=1920:800:0:140, subtitles='/in/file.mkv', scale=320:240`,
+		},
+		{
+			name:   "deinterlace",
+			fields: fields{subtitle: `/in/file.mkv`, width: 320, height: 240, upscaling: true, deint: DeinterlacerYadif},
+			want:   `yadif, subtitles='/in/file.mkv', scale=320:240`,
+		},
+		{
+			name:   "inverse telecine",
+			fields: fields{subtitle: `/in/file.mkv`, width: 320, height: 240, upscaling: true, ivtc: true},
+			want:   `fieldmatch, decimate, subtitles='/in/file.mkv', scale=320:240`,
+		},
+		{
+			name:   "inverse telecine with deinterlace",
+			fields: fields{width: 320, height: 240, upscaling: true, ivtc: true, deint: DeinterlacerBwdif, crop: Crop{Width: 704, Height: 480, X: 8}},
+			want:   `fieldmatch, bwdif=deint=interlaced, decimate, crop=704:480:8:0, scale=320:240`,
+		},
+		{
+			name:   "subtitle after scale",
+			fields: fields{subtitle: `/in/file.mkv`, width: 320, height: 240, upscaling: true, crop: Crop{Width: 1920, Height: 800, Y: 140}, afterScale: true},
+			want:   `crop=1920:800:0:140, scale=320:240, subtitles='/in/file.mkv'`,
+		},
+		{
+			name:   "offset",
+			fields: fields{subtitle: `/in/file.mkv`, width: 320, height: 240, upscaling: true, offset: 90500 * time.Millisecond},
+			want:   `setpts=PTS+90.5/TB, subtitles='/in/file.mkv', scale=320:240, setpts=PTS-STARTPTS`,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			f := Filter{
-				Subtitle:  tt.fields.subtitle,
-				Width:     tt.fields.width,
-				Height:    tt.fields.height,
-				Upscaling: tt.fields.upscaling,
+				Subtitle:   tt.fields.subtitle,
+				ForceStyle: tt.fields.forceStyle,
+				Width:      tt.fields.width,
+				Height:     tt.fields.height,
+				Upscaling:  tt.fields.upscaling,
+				Offset:     tt.fields.offset,
+				Crop:       tt.fields.crop,
+
+				Deinterlacer:    tt.fields.deint,
+				InverseTelecine: tt.fields.ivtc,
+
+				SubtitleAfterScale: tt.fields.afterScale,
 			}
 			if got := f.String(); got != tt.want {
 				t.Errorf("String() = %v, want %v", got, tt.want)
@@ -57,60 +114,33 @@ func TestFilter_String(t *testing.T) {
 	}
 }
 
-func TestBitrateToKilobit(t *testing.T) {
-	type args struct {
-		bitrate string
+func TestTranscoder_VideoCopy(t *testing.T) {
+	tr := NewTranscoder("ffmpeg", "/in/file.mkv", "/out", Megabit, Filter{Width: -2, Height: 720})
+	if tr.Remux() {
+		t.Fatal("Remux() = true before VideoCopy")
 	}
-	tests := []struct {
-		name string
-		args args
-		want int64
-	}{
-		{
-			name: "kilobit",
-			args: args{bitrate: "1371k"},
-			want: 1371,
-		},
-		{
-			name: "megabit",
-			args: args{bitrate: "1M"},
-			want: 1024,
-		},
+	tr.VideoCopy()
+	if !tr.Remux() {
+		t.Fatal("Remux() = false after VideoCopy")
 	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := BitrateToKilobit(tt.args.bitrate); got != tt.want {
-				t.Errorf("BitrateToKilobit() = %v, want %v", got, tt.want)
-			}
-		})
+	got := strings.Join(tr.SinglePass().Args, " ")
+	if !strings.Contains(got, "-i /in/file.mkv -map 0:v") || !strings.Contains(got, "-c:v copy") {
+		t.Errorf("SinglePass() = %s, want the video mapped and copied", got)
+	}
+	for _, flag := range []string{"-b:v", "-tune", "-preset", "-pix_fmt", "-filter_complex"} {
+		if strings.Contains(got, flag) {
+			t.Errorf("SinglePass() = %s, want no %s", got, flag)
+		}
 	}
 }
 
-func TestKilobitToBitrate(t *testing.T) {
-	type args struct {
-		kilobit int64
-	}
-	tests := []struct {
-		name string
-		args args
-		want string
-	}{
-		{
-			name: "kilobit",
-			args: args{kilobit: 1371},
-			want: "1371k",
-		},
-		{
-			name: "megabit",
-			args: args{kilobit: 1024},
-			want: "1024k",
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := KilobitToBitrate(tt.args.kilobit); got != tt.want {
-				t.Errorf("BitrateToKilobit() = %v, want %v", got, tt.want)
-			}
-		})
+func TestNewVideoChunkTranscoder_Window(t *testing.T) {
+	start, length := 90500*time.Millisecond, 312417*time.Millisecond
+	tr := NewVideoChunkTranscoder("ffmpeg", "/in/file.mkv", "chunk-001.mp4", "/out", Megabit, Filter{Height: 720}, start, length)
+	for _, cmd := range []*exec.Cmd{tr.FirstPass(), tr.SecondPass()} {
+		got := strings.Join(cmd.Args, " ")
+		if !strings.Contains(got, "-ss 90.5 -i /in/file.mkv") || !strings.Contains(got, "-t 312.417") {
+			t.Errorf("args = %s, want -ss 90.5 and -t 312.417", got)
+		}
 	}
 }
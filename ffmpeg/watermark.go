@@ -0,0 +1,121 @@
+package ffmpeg
+
+import (
+	"errors"
+	"fmt"
+	"github.com/shiroi-usagi/burner/filtergraph"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Corner is the corner of the frame which holds the watermark.
+type Corner string
+
+const (
+	CornerTopLeft     Corner = "top-left"
+	CornerTopRight    Corner = "top-right"
+	CornerBottomLeft  Corner = "bottom-left"
+	CornerBottomRight Corner = "bottom-right"
+)
+
+// ParseCorner parses the name of a corner, e.g. bottom-right.
+func ParseCorner(s string) (Corner, error) {
+	switch c := Corner(strings.ToLower(strings.TrimSpace(s))); c {
+	case CornerTopLeft, CornerTopRight, CornerBottomLeft, CornerBottomRight:
+		return c, nil
+	}
+	return "", fmt.Errorf("unknown corner `%s`, expected top-left, top-right, bottom-left or bottom-right", s)
+}
+
+// position is the x and y expressions of the overlay filter.
+func (c Corner) position(margin int) (string, string) {
+	m := strconv.Itoa(margin)
+	x, y := m, m
+	if c == CornerTopRight || c == CornerBottomRight {
+		x = "main_w-overlay_w"
+		if margin != 0 {
+			x += "-" + m
+		}
+	}
+	if c == CornerBottomLeft || c == CornerBottomRight {
+		y = "main_h-overlay_h"
+		if margin != 0 {
+			y += "-" + m
+		}
+	}
+	return x, y
+}
+
+// Watermark is an image drawn over the frames, e.g. the logo of a group.
+type Watermark struct {
+	// Image is the path of the image, empty disables the watermark
+	Image string
+	// Corner holds the image, bottom-right when it is not set
+	Corner Corner
+	// Margin is the distance from the edges in pixels
+	Margin int
+	// Opacity of the image between 0 and 1, zero is treated as one
+	Opacity float64
+	// Scale is the height of the image relative to the height of the
+	// frame, zero keeps the size of the image
+	Scale float64
+	// Start and End limit the time the image is shown, zero End shows
+	// it until the end of the input
+	Start, End time.Duration
+}
+
+// Validate checks the settings of the watermark.
+func (w Watermark) Validate() error {
+	if w.Opacity < 0 || w.Opacity > 1 {
+		return fmt.Errorf("invalid watermark opacity %g, expected a value between 0 and 1", w.Opacity)
+	}
+	if w.Scale < 0 || w.Scale > 1 {
+		return fmt.Errorf("invalid watermark scale %g, expected a value between 0 and 1", w.Scale)
+	}
+	if w.Margin < 0 {
+		return fmt.Errorf("invalid watermark margin %d", w.Margin)
+	}
+	if w.Start < 0 || w.End != 0 && w.End <= w.Start {
+		return errors.New("invalid watermark window, the end must be after the start")
+	}
+	return nil
+}
+
+// overlay draws the watermark read from the input pad over the frames of
+// the main pad. The timestamps of the frames are shifted by offset
+// compared to the source.
+func (w Watermark) overlay(g *filtergraph.Graph, main, input string, offset time.Duration) *filtergraph.Chain {
+	logo := filtergraph.NewChain(input).Add(0, filtergraph.New("format").Arg("rgba")).Output("logo")
+	if w.Opacity > 0 && w.Opacity < 1 {
+		logo.Add(0, filtergraph.New("colorchannelmixer").Opt("aa", strconv.FormatFloat(w.Opacity, 'f', -1, 64)))
+	}
+	g.Add(logo)
+
+	base, image := main, "logo"
+	if w.Scale > 0 {
+		// The size follows the output, whatever the resolution of the source
+		scale := strconv.FormatFloat(w.Scale, 'f', -1, 64)
+		g.Add(filtergraph.NewChain("logo", main).
+			Add(0, filtergraph.New("scale2ref").Opt("w", "-1").Opt("h", "main_h*"+scale)).
+			Output("wm", "base"))
+		base, image = "base", "wm"
+	}
+
+	corner := w.Corner
+	if corner == "" {
+		corner = CornerBottomRight
+	}
+	x, y := corner.position(w.Margin)
+	// The image is looped, the output ends with the frames
+	overlay := filtergraph.New("overlay").Opt("x", x).Opt("y", y).Opt("shortest", "1")
+	if w.Start != 0 || w.End != 0 {
+		start := formatSeconds(w.Start - offset)
+		if w.End != 0 {
+			overlay = overlay.Opt("enable", fmt.Sprintf("between(t,%s,%s)", start, formatSeconds(w.End-offset)))
+		} else {
+			overlay = overlay.Opt("enable", fmt.Sprintf("gte(t,%s)", start))
+		}
+	}
+	return filtergraph.NewChain(base, image).Add(0, overlay)
+}
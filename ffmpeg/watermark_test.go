@@ -0,0 +1,69 @@
+package ffmpeg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatermark_Graph(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   string
+	}{
+		{
+			name:   "bottom right",
+			filter: Filter{Height: 720, Width: -2, Upscaling: true, Watermark: Watermark{Image: "/in/logo.png", Margin: 16}},
+			want:   `[0:v]scale=-2:720[main];[1:v]format=rgba[logo];[main][logo]overlay=x=main_w-overlay_w-16:y=main_h-overlay_h-16:shortest=1`,
+		},
+		{
+			name:   "top left",
+			filter: Filter{Watermark: Watermark{Image: "/in/logo.png", Corner: CornerTopLeft, Margin: 8}},
+			want:   `[0:v]null[main];[1:v]format=rgba[logo];[main][logo]overlay=x=8:y=8:shortest=1`,
+		},
+		{
+			name:   "opacity and scale",
+			filter: Filter{Subtitle: "/in/file.mkv", Watermark: Watermark{Image: "/in/logo.png", Corner: CornerTopRight, Opacity: 0.5, Scale: 0.1}},
+			want:   `[0:v]subtitles='/in/file.mkv'[main];[1:v]format=rgba, colorchannelmixer=aa=0.5[logo];[logo][main]scale2ref=w=-1:h=main_h*0.1[wm][base];[base][wm]overlay=x=main_w-overlay_w:y=0:shortest=1`,
+		},
+		{
+			name:   "window",
+			filter: Filter{Watermark: Watermark{Image: "/in/logo.png", Corner: CornerBottomLeft, Start: 10 * time.Second, End: 70 * time.Second}},
+			want:   `[0:v]null[main];[1:v]format=rgba[logo];[main][logo]overlay=x=0:y=main_h-overlay_h:shortest=1:enable='between(t,10,70)'`,
+		},
+		{
+			name:   "window of a chunk",
+			filter: Filter{Offset: 60 * time.Second, Watermark: Watermark{Image: "/in/logo.png", Start: 90 * time.Second}},
+			want:   `[0:v]setpts=PTS+60/TB, setpts=PTS-STARTPTS[main];[1:v]format=rgba[logo];[main][logo]overlay=x=main_w-overlay_w:y=main_h-overlay_h:shortest=1:enable='gte(t,30)'`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.String(); got != tt.want {
+				t.Errorf("String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatermark_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		watermark Watermark
+		wantErr   bool
+	}{
+		{name: "defaults", watermark: Watermark{Image: "logo.png"}},
+		{name: "all set", watermark: Watermark{Image: "logo.png", Opacity: 0.8, Scale: 0.1, Margin: 10, Start: time.Second, End: time.Minute}},
+		{name: "opacity", watermark: Watermark{Image: "logo.png", Opacity: 1.5}, wantErr: true},
+		{name: "scale", watermark: Watermark{Image: "logo.png", Scale: -0.1}, wantErr: true},
+		{name: "margin", watermark: Watermark{Image: "logo.png", Margin: -1}, wantErr: true},
+		{name: "window", watermark: Watermark{Image: "logo.png", Start: time.Minute, End: time.Second}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.watermark.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,8 +2,11 @@ package ffprobe
 
 import (
 	"encoding/json"
+	"errors"
 	"os/exec"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 type format struct {
@@ -31,3 +34,168 @@ func Duration(path, input string) (float64, error) {
 	}
 	return strconv.ParseFloat(string(e.Format.Duration), 64)
 }
+
+type Tags struct {
+	Language string `json:"language"`
+	Title    string `json:"title"`
+	// BPS is the bitrate written by mkvmerge into Matroska files.
+	BPS    string `json:"BPS"`
+	BPSEng string `json:"BPS-eng"`
+}
+
+type Disposition struct {
+	Default int `json:"default"`
+	Forced  int `json:"forced"`
+}
+
+// Stream is a stream of the input as reported by ffprobe.
+type Stream struct {
+	Index       int         `json:"index"`
+	CodecType   string      `json:"codec_type"`
+	CodecName   string      `json:"codec_name"`
+	Channels    int         `json:"channels"`
+	Width       int         `json:"width"`
+	Height      int         `json:"height"`
+	BitRate     string      `json:"bit_rate"`
+	RFrameRate  string      `json:"r_frame_rate"`
+	Tags        Tags        `json:"tags"`
+	Disposition Disposition `json:"disposition"`
+
+	// AvgFrameRate differs from RFrameRate for variable frame rate videos
+	AvgFrameRate string `json:"avg_frame_rate"`
+	// The color properties of video streams, e.g. smpte2084 and bt2020
+	ColorTransfer  string `json:"color_transfer"`
+	ColorPrimaries string `json:"color_primaries"`
+	// PixFmt is the pixel format of video streams, e.g. yuv420p
+	PixFmt string `json:"pix_fmt"`
+}
+
+type streamEntries struct {
+	Streams []Stream `json:"streams"`
+}
+
+// Streams lists the streams of the input.
+func Streams(path, input string) ([]Stream, error) {
+	var args []string
+	args = append(args, "-i", input)     // Input file url
+	args = append(args, "-show_streams") // Show information about each media stream.
+	args = append(args, "-v", "quiet")   // Show nothing at all; be silent.
+	args = append(args, "-of", "json")   // Set the output printing format.
+	cmd := exec.Command(path, args...)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	var e streamEntries
+	if err = json.Unmarshal(out, &e); err != nil {
+		return nil, err
+	}
+	return e.Streams, nil
+}
+
+// OfType filters the streams by codec type, e.g. audio or subtitle.
+func OfType(streams []Stream, codecType string) []Stream {
+	var filtered []Stream
+	for _, s := range streams {
+		if s.CodecType == codecType {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
+
+// Keyframes lists the timestamps of the keyframes of the first video
+// stream in seconds. Only the packets are read, nothing is decoded.
+func Keyframes(path, input string) ([]float64, error) {
+	var args []string
+	args = append(args, "-i", input)                              // Input file url
+	args = append(args, "-select_streams", "v:0")                 // Select the first video stream.
+	args = append(args, "-show_entries", "packet=pts_time,flags") // Set list of entries to show.
+	args = append(args, "-v", "quiet")                            // Show nothing at all; be silent.
+	args = append(args, "-of", "csv=p=0")                         // Set the output printing format.
+	cmd := exec.Command(path, args...)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseKeyframes(string(out)), nil
+}
+
+// parseKeyframes parses the `pts_time,flags` lines of ffprobe.
+func parseKeyframes(out string) []float64 {
+	var keyframes []float64
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), ",")
+		if len(fields) < 2 || !strings.Contains(fields[1], "K") {
+			continue
+		}
+		pts, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			// Packets without timestamp
+			continue
+		}
+		keyframes = append(keyframes, pts)
+	}
+	sort.Float64s(keyframes)
+	return keyframes
+}
+
+// Bitrate is the bitrate of the stream in bits per second from the
+// stream header or the statistics tags of Matroska, zero when unknown.
+func (s Stream) Bitrate() int64 {
+	for _, v := range []string{s.BitRate, s.Tags.BPS, s.Tags.BPSEng} {
+		if b, err := strconv.ParseInt(v, 10, 64); err == nil && b > 0 {
+			return b
+		}
+	}
+	return 0
+}
+
+// VideoBitrate estimates the bitrate of the first video stream of the
+// input in bits per second from its probed streams. Without a bitrate in
+// the headers the packet sizes of the stream are summed over the duration
+// of the input.
+func VideoBitrate(path, input string, streams []Stream, duration float64) (int64, error) {
+	video := OfType(streams, "video")
+	if len(video) == 0 {
+		return 0, errors.New("ffprobe: no video stream")
+	}
+	if b := video[0].Bitrate(); b > 0 {
+		return b, nil
+	}
+	if duration <= 0 {
+		return 0, errors.New("ffprobe: unknown duration")
+	}
+	size, err := packetSize(path, input)
+	if err != nil {
+		return 0, err
+	}
+	return int64(float64(size) * 8 / duration), nil
+}
+
+// packetSize sums the packet sizes of the first video stream in bytes.
+func packetSize(path, input string) (int64, error) {
+	var args []string
+	args = append(args, "-i", input)                    // Input file url
+	args = append(args, "-select_streams", "v:0")       // Select the first video stream.
+	args = append(args, "-show_entries", "packet=size") // Set list of entries to show.
+	args = append(args, "-v", "quiet")                  // Show nothing at all; be silent.
+	args = append(args, "-of", "csv=p=0")               // Set the output printing format.
+	cmd := exec.Command(path, args...)
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, err
+	}
+	return sumLines(string(out)), nil
+}
+
+// sumLines sums the numbers of the lines, other lines are skipped.
+func sumLines(out string) int64 {
+	var sum int64
+	for _, line := range strings.Split(out, "\n") {
+		if n, err := strconv.ParseInt(strings.Trim(line, " \r,"), 10, 64); err == nil {
+			sum += n
+		}
+	}
+	return sum
+}
@@ -0,0 +1,69 @@
+package ffprobe
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeyframes(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want []float64
+	}{
+		{
+			name: "empty",
+			out:  "",
+		},
+		{
+			name: "keyframes only",
+			out:  "0.000000,K_\n0.041000,__\n10.010000,K_\nN/A,K_\n5.005000,K_\n",
+			want: []float64{0, 5.005, 10.01},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseKeyframes(tt.out); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseKeyframes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStream_Bitrate(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream Stream
+		want   int64
+	}{
+		{name: "unknown", stream: Stream{BitRate: "N/A"}},
+		{name: "header", stream: Stream{BitRate: "1500000", Tags: Tags{BPS: "1400000"}}, want: 1500000},
+		{name: "matroska tag", stream: Stream{Tags: Tags{BPS: "1400000"}}, want: 1400000},
+		{name: "old matroska tag", stream: Stream{Tags: Tags{BPSEng: "1300000"}}, want: 1300000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.stream.Bitrate(); got != tt.want {
+				t.Errorf("Bitrate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSumLines(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want int64
+	}{
+		{name: "empty", out: ""},
+		{name: "sizes", out: "1000\n250,\r\nN/A\n50\n", want: 1300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sumLines(tt.out); got != tt.want {
+				t.Errorf("sumLines() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package filtergraph
+
+import (
+	"sort"
+	"strings"
+)
+
+// arg is a positional or named value of a filter.
+type arg struct {
+	key   string
+	value string
+	quote bool
+}
+
+// Filter is a single filter with its arguments, e.g. scale=1280:-2.
+type Filter struct {
+	name string
+	args []arg
+}
+
+// New creates a filter without arguments.
+func New(name string) Filter {
+	return Filter{name: name}
+}
+
+// Name is the name of the filter.
+func (f Filter) Name() string {
+	return f.name
+}
+
+// Arg appends a positional value, it is quoted when needed.
+func (f Filter) Arg(value string) Filter {
+	return f.with(arg{value: value})
+}
+
+// Opt appends a named value, it is quoted when needed.
+func (f Filter) Opt(key, value string) Filter {
+	return f.with(arg{key: key, value: value})
+}
+
+// QuotedArg appends a positional value which is always quoted, e.g. a file name.
+func (f Filter) QuotedArg(value string) Filter {
+	return f.with(arg{value: value, quote: true})
+}
+
+// QuotedOpt appends a named value which is always quoted, e.g. a style.
+func (f Filter) QuotedOpt(key, value string) Filter {
+	return f.with(arg{key: key, value: value, quote: true})
+}
+
+func (f Filter) with(a arg) Filter {
+	args := make([]arg, len(f.args), len(f.args)+1)
+	copy(args, f.args)
+	f.args = append(args, a)
+	return f
+}
+
+func (f Filter) String() string {
+	if len(f.args) == 0 {
+		return f.name
+	}
+	var args []string
+	for _, a := range f.args {
+		v := escapeGraph(escapeOption(a.value), a.quote)
+		if a.key != "" {
+			v = a.key + "=" + v
+		}
+		args = append(args, v)
+	}
+	return f.name + "=" + strings.Join(args, ":")
+}
+
+// escapeOption escapes the special characters of the option parser.
+func escapeOption(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`)
+	return r.Replace(v)
+}
+
+// escapeGraph quotes the value for the graph parser when it holds any
+// of its special characters, or when quote is set.
+func escapeGraph(v string, quote bool) string {
+	if !quote && !strings.ContainsAny(v, "\\'[],; \t\n") {
+		return v
+	}
+	// A quote can not be escaped between quotes, it is closed first
+	return "'" + strings.ReplaceAll(v, "'", `'\''`) + "'"
+}
+
+// staged is a filter of a chain with its place in the order.
+type staged struct {
+	stage  int
+	filter Filter
+}
+
+// Chain is a linear sequence of filters between labelled pads.
+//
+// The filters are ordered by their stage, filters of the same stage
+// keep the order they were added.
+type Chain struct {
+	inputs  []string
+	outputs []string
+	filters []staged
+}
+
+// NewChain creates a chain reading the given pads, e.g. 0:v.
+func NewChain(inputs ...string) *Chain {
+	return &Chain{inputs: inputs}
+}
+
+// Add inserts the filters into the chain after the filters of the
+// same or of an earlier stage.
+func (c *Chain) Add(stage int, filters ...Filter) *Chain {
+	for _, f := range filters {
+		c.filters = append(c.filters, staged{stage: stage, filter: f})
+	}
+	sort.SliceStable(c.filters, func(i, k int) bool {
+		return c.filters[i].stage < c.filters[k].stage
+	})
+	return c
+}
+
+// Input sets the pads read by the chain.
+func (c *Chain) Input(inputs ...string) *Chain {
+	c.inputs = inputs
+	return c
+}
+
+// Output sets the pads written by the chain.
+func (c *Chain) Output(outputs ...string) *Chain {
+	c.outputs = outputs
+	return c
+}
+
+// Len is the number of filters in the chain.
+func (c *Chain) Len() int {
+	return len(c.filters)
+}
+
+// Filters are the filters of the chain in order.
+func (c *Chain) Filters() []Filter {
+	var filters []Filter
+	for _, f := range c.filters {
+		filters = append(filters, f.filter)
+	}
+	return filters
+}
+
+func (c *Chain) String() string {
+	var sb strings.Builder
+	for _, in := range c.inputs {
+		sb.WriteString("[" + in + "]")
+	}
+	var filters []string
+	for _, f := range c.filters {
+		filters = append(filters, f.filter.String())
+	}
+	if len(filters) == 0 {
+		// Pads can only be connected through a filter
+		filters = append(filters, "null")
+	}
+	sb.WriteString(strings.Join(filters, ", "))
+	for _, out := range c.outputs {
+		sb.WriteString("[" + out + "]")
+	}
+	return sb.String()
+}
+
+// Graph is a filtergraph description of ffmpeg, its chains are
+// connected through labelled pads.
+//
+// The values of the filters are escaped for both levels of the ffmpeg
+// parser, the options of the filter and the graph.
+type Graph struct {
+	chains []*Chain
+}
+
+// Add appends the chains to the graph.
+func (g *Graph) Add(chains ...*Chain) *Graph {
+	g.chains = append(g.chains, chains...)
+	return g
+}
+
+// Chains are the chains of the graph in order.
+func (g Graph) Chains() []*Chain {
+	return g.chains
+}
+
+// IsEmpty reports whether the graph has no filter.
+func (g Graph) IsEmpty() bool {
+	for _, c := range g.chains {
+		if c.Len() > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (g Graph) String() string {
+	var chains []string
+	for _, c := range g.chains {
+		if c.Len() == 0 && len(c.inputs) == 0 && len(c.outputs) == 0 {
+			continue
+		}
+		chains = append(chains, c.String())
+	}
+	return strings.Join(chains, ";")
+}
@@ -0,0 +1,119 @@
+package filtergraph
+
+import (
+	"testing"
+)
+
+func TestFilter_String(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   string
+	}{
+		{
+			name:   "no arguments",
+			filter: New("decimate"),
+			want:   `decimate`,
+		},
+		{
+			name:   "positional and named",
+			filter: New("scale").Arg("1280").Arg("-2").Opt("flags", "bicubic"),
+			want:   `scale=1280:-2:flags=bicubic`,
+		},
+		{
+			name:   "option separator",
+			filter: New("subtitles").Arg(`C:\in\file.mkv`),
+			want:   `subtitles='C\:\\in\\file.mkv'`,
+		},
+		{
+			name:   "graph separators",
+			filter: New("scale").Arg("min(320,iw)").Arg("min(240,ih)"),
+			want:   `scale='min(320,iw)':'min(240,ih)'`,
+		},
+		{
+			name:   "quote",
+			filter: New("drawtext").Opt("text", "it's"),
+			want:   `drawtext=text='it\'\''s'`,
+		},
+		{
+			name:   "always quoted",
+			filter: New("subtitles").QuotedArg("/in/file.mkv").QuotedOpt("force_style", "FontName=Arial"),
+			want:   `subtitles='/in/file.mkv':force_style='FontName=Arial'`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.String(); got != tt.want {
+				t.Errorf("String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_Immutable(t *testing.T) {
+	base := New("scale").Arg("320")
+	a := base.Arg("240")
+	b := base.Arg("480")
+	if a.String() != "scale=320:240" || b.String() != "scale=320:480" {
+		t.Errorf("derived filters share arguments: %v, %v", a, b)
+	}
+}
+
+func TestGraph_String(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph func() Graph
+		want  string
+	}{
+		{
+			name: "stages",
+			graph: func() Graph {
+				var g Graph
+				g.Add(NewChain().
+					Add(20, New("scale").Arg("320").Arg("240")).
+					Add(0, New("crop").Arg("704").Arg("480")).
+					Add(10, New("subtitles").QuotedArg("a.ass")).
+					Add(20, New("format").Arg("yuv420p")))
+				return g
+			},
+			want: `crop=704:480, subtitles='a.ass', scale=320:240, format=yuv420p`,
+		},
+		{
+			name: "labelled pads",
+			graph: func() Graph {
+				var g Graph
+				g.Add(
+					NewChain("0:v").Add(0, New("split").Arg("2")).Output("a", "b"),
+					NewChain("a", "b").Add(0, New("hstack")),
+				)
+				return g
+			},
+			want: `[0:v]split=2[a][b];[a][b]hstack`,
+		},
+		{
+			name: "empty chain with pads",
+			graph: func() Graph {
+				var g Graph
+				g.Add(NewChain("0:v").Output("v"))
+				return g
+			},
+			want: `[0:v]null[v]`,
+		},
+		{
+			name: "empty chain without pads",
+			graph: func() Graph {
+				var g Graph
+				g.Add(NewChain(), NewChain().Add(0, New("yadif")))
+				return g
+			},
+			want: `yadif`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.graph().String(); got != tt.want {
+				t.Errorf("String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package burner
+
+import (
+	"fmt"
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/ffprobe"
+	"math"
+	"strings"
+)
+
+// RateMode decides the frame rate of the outputs.
+//
+// The zero value keeps the frame rate.
+type RateMode string
+
+const (
+	// RateKeep keeps the frames, the timestamps of variable frame rate
+	// inputs are kept too.
+	RateKeep RateMode = "keep"
+	// RateCap converts the inputs above the frame rate to it.
+	RateCap RateMode = "cap"
+	// RateCFR converts every input to the constant frame rate.
+	RateCFR RateMode = "cfr"
+)
+
+// ParseRateMode parses keep, cap or cfr.
+func ParseRateMode(s string) (RateMode, error) {
+	switch m := RateMode(strings.ToLower(strings.TrimSpace(s))); m {
+	case RateKeep, RateCap, RateCFR:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid value `%s`, expected keep, cap or cfr", s)
+}
+
+// variableRateTolerance is the relative difference of the frame rates
+// probed for a video which is still treated as constant, the average
+// of constant videos is not always exact.
+const variableRateTolerance = 0.01
+
+// probeFrameRate returns the frame rate and the average frame rate of
+// the first probed video stream, zero when they are not known. It also
+// reports whether the stream is soft telecined, its frames are already
+// at the rate of the film.
+func probeFrameRate(streams []ffprobe.Stream) (ffmpeg.FrameRate, ffmpeg.FrameRate, bool) {
+	video := ffprobe.OfType(streams, "video")
+	if len(video) == 0 {
+		return ffmpeg.FrameRate{}, ffmpeg.FrameRate{}, false
+	}
+	// Unknown rates are reported as 0/0
+	rate, _ := ffmpeg.ParseFrameRate(video[0].RFrameRate)
+	average, _ := ffmpeg.ParseFrameRate(video[0].AvgFrameRate)
+	pulldown := isPulldownRate(rate, average)
+	if isScanRate(rate, average) {
+		// The frames are decoded at the average rate
+		rate = average
+	}
+	return rate, average, pulldown
+}
+
+// isScanRate reports whether the frame rate is not the rate of the
+// frames but of the scan. Interlaced streams report the field rate,
+// twice the average, soft telecined streams the rate of the pulldown,
+// 5/4 of the average.
+func isScanRate(rate, average ffmpeg.FrameRate) bool {
+	if rate.IsZero() || average.IsZero() {
+		return false
+	}
+	// rate/average is exactly 2/1, or 5/4 for the pulldown
+	n, d := int64(rate.Num)*int64(average.Den), int64(rate.Den)*int64(average.Num)
+	return n == 2*d || isPulldownRate(rate, average)
+}
+
+// isPulldownRate reports whether the frame rate is the rate of the
+// pulldown of a soft telecined stream, exactly 5/4 of the average.
+func isPulldownRate(rate, average ffmpeg.FrameRate) bool {
+	if rate.IsZero() || average.IsZero() {
+		return false
+	}
+	n, d := int64(rate.Num)*int64(average.Den), int64(rate.Den)*int64(average.Num)
+	return 4*n == 5*d
+}
+
+// telecineRates returns the rates of the frames after the inverse
+// telecine. The frames of soft telecined streams are already at the
+// rate of the film, only the rates of the telecined frames are
+// decimated.
+func telecineRates(rate, average ffmpeg.FrameRate, pulldown bool) (ffmpeg.FrameRate, ffmpeg.FrameRate) {
+	if pulldown {
+		return rate, average
+	}
+	return rate.Decimated(), average.Decimated()
+}
+
+// isVariableRate reports whether the probed frame rates are of a
+// variable frame rate video.
+func isVariableRate(rate, average ffmpeg.FrameRate) bool {
+	if rate.IsZero() || average.IsZero() || isScanRate(rate, average) {
+		return false
+	}
+	return math.Abs(rate.Float()-average.Float()) > rate.Float()*variableRateTolerance
+}
+
+// rateStages decides the frame rate stages of the filter from the
+// settings and the rates of the input, after the inverse telecine.
+// It returns the frame rate of the output, whether the frames are
+// converted to it and whether the timestamps of the input are kept.
+func rateStages(v VideoConf, rate, average ffmpeg.FrameRate) (ffmpeg.FrameRate, bool, bool) {
+	switch v.RateMode {
+	case RateCFR:
+		if !v.FrameRate.IsZero() {
+			return v.FrameRate, true, false
+		}
+		// The frames of the input at the rate of the input
+		return rate, !rate.IsZero(), false
+	case RateCap:
+		if !v.FrameRate.IsZero() && (rate.IsZero() || rate.Float() > v.FrameRate.Float()) {
+			return v.FrameRate, true, false
+		}
+	}
+	return rate, false, isVariableRate(rate, average)
+}
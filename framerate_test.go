@@ -0,0 +1,110 @@
+package burner
+
+import (
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/ffprobe"
+	"testing"
+)
+
+func TestRateStages(t *testing.T) {
+	film := ffmpeg.NewFrameRate(24000, 1001)
+	ntsc := ffmpeg.NewFrameRate(30000, 1001)
+	high := ffmpeg.NewFrameRate(60, 1)
+	vfr := ffmpeg.NewFrameRate(2997, 125)
+	tests := []struct {
+		name         string
+		v            VideoConf
+		rate         ffmpeg.FrameRate
+		average      ffmpeg.FrameRate
+		want         ffmpeg.FrameRate
+		wantConvert  bool
+		wantVariable bool
+	}{
+		{name: "keep", rate: film, average: film, want: film},
+		{name: "keep variable", v: VideoConf{RateMode: RateKeep}, rate: ntsc, average: vfr, want: ntsc, wantVariable: true},
+		{name: "keep unknown average", rate: film, want: film},
+		{name: "cap above", v: VideoConf{RateMode: RateCap, FrameRate: ntsc}, rate: high, average: high, want: ntsc, wantConvert: true},
+		{name: "cap below", v: VideoConf{RateMode: RateCap, FrameRate: ntsc}, rate: film, average: film, want: film},
+		{name: "cap variable below", v: VideoConf{RateMode: RateCap, FrameRate: high}, rate: ntsc, average: vfr, want: ntsc, wantVariable: true},
+		{name: "cfr", v: VideoConf{RateMode: RateCFR, FrameRate: film}, rate: ntsc, average: vfr, want: film, wantConvert: true},
+		{name: "cfr at the input rate", v: VideoConf{RateMode: RateCFR}, rate: ntsc, average: vfr, want: ntsc, wantConvert: true},
+		{name: "cfr without probe", v: VideoConf{RateMode: RateCFR}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, convert, variable := rateStages(tt.v, tt.rate, tt.average)
+			if got != tt.want || convert != tt.wantConvert || variable != tt.wantVariable {
+				t.Errorf("rateStages() = %v, %v, %v, want %v, %v, %v", got, convert, variable, tt.want, tt.wantConvert, tt.wantVariable)
+			}
+		})
+	}
+}
+
+func TestIsVariableRate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    string
+		average string
+		want    bool
+	}{
+		{name: "constant", rate: "24000/1001", average: "24000/1001"},
+		{name: "rounded average", rate: "24000/1001", average: "1019001/42500"},
+		{name: "variable", rate: "30000/1001", average: "2997/125", want: true},
+		{name: "field rate", rate: "60000/1001", average: "30000/1001"},
+		{name: "field rate pal", rate: "50/1", average: "25/1"},
+		{name: "soft telecine", rate: "30000/1001", average: "24000/1001"},
+		{name: "unknown average", rate: "24000/1001", average: "0/0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, _ := ffmpeg.ParseFrameRate(tt.rate)
+			average, _ := ffmpeg.ParseFrameRate(tt.average)
+			if got := isVariableRate(rate, average); got != tt.want {
+				t.Errorf("isVariableRate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeFrameRate(t *testing.T) {
+	tests := []struct {
+		name         string
+		streams      []ffprobe.Stream
+		want         ffmpeg.FrameRate
+		wantPulldown bool
+	}{
+		{name: "no video", streams: []ffprobe.Stream{{CodecType: "audio"}}},
+		{name: "progressive", streams: []ffprobe.Stream{{CodecType: "video", RFrameRate: "24000/1001", AvgFrameRate: "24000/1001"}}, want: ffmpeg.NewFrameRate(24000, 1001)},
+		{name: "field rate", streams: []ffprobe.Stream{{CodecType: "video", RFrameRate: "60000/1001", AvgFrameRate: "30000/1001"}}, want: ffmpeg.NewFrameRate(30000, 1001)},
+		{name: "soft telecine", streams: []ffprobe.Stream{{CodecType: "video", RFrameRate: "30000/1001", AvgFrameRate: "24000/1001"}}, want: ffmpeg.NewFrameRate(24000, 1001), wantPulldown: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, pulldown := probeFrameRate(tt.streams)
+			if got != tt.want || pulldown != tt.wantPulldown {
+				t.Errorf("probeFrameRate() = %v, %v, want %v, %v", got, pulldown, tt.want, tt.wantPulldown)
+			}
+		})
+	}
+}
+
+func TestTelecineRates(t *testing.T) {
+	film := ffmpeg.NewFrameRate(24000, 1001)
+	tests := []struct {
+		name    string
+		streams []ffprobe.Stream
+		want    ffmpeg.FrameRate
+	}{
+		{name: "hard telecine", streams: []ffprobe.Stream{{CodecType: "video", RFrameRate: "30000/1001", AvgFrameRate: "30000/1001"}}, want: film},
+		{name: "field rate", streams: []ffprobe.Stream{{CodecType: "video", RFrameRate: "60000/1001", AvgFrameRate: "30000/1001"}}, want: film},
+		{name: "soft telecine", streams: []ffprobe.Stream{{CodecType: "video", RFrameRate: "30000/1001", AvgFrameRate: "24000/1001"}}, want: film},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, average, pulldown := probeFrameRate(tt.streams)
+			if got, gotAverage := telecineRates(rate, average, pulldown); got != tt.want || gotAverage != tt.want {
+				t.Errorf("telecineRates() = %v, %v, want %v", got, gotAverage, tt.want)
+			}
+		})
+	}
+}
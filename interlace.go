@@ -0,0 +1,80 @@
+package burner
+
+import (
+	"fmt"
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"strings"
+	"time"
+)
+
+// Switch is a setting which can be detected for each input.
+//
+// The zero value is off.
+type Switch string
+
+const (
+	SwitchAuto Switch = "auto"
+	SwitchOn   Switch = "on"
+	SwitchOff  Switch = "off"
+)
+
+// ParseSwitch parses auto, on or off.
+func ParseSwitch(s string) (Switch, error) {
+	switch v := Switch(strings.ToLower(strings.TrimSpace(s))); v {
+	case SwitchAuto, SwitchOn, SwitchOff:
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid value `%s`, expected auto, on or off", s)
+}
+
+const (
+	// idetSamples is the number of parts of the input checked for interlacing.
+	idetSamples = 5
+	// idetSampleDuration is the length of a part checked for interlacing.
+	idetSampleDuration = 10 * time.Second
+
+	// Below progressiveRatio of interlaced frames the input is progressive.
+	progressiveRatio = 0.1
+	// From interlacedRatio of interlaced frames the input is interlaced,
+	// between the two it is telecined. The 3:2 pulldown combs two of
+	// every five frames.
+	interlacedRatio = 0.6
+)
+
+// detectInterlace counts the interlaced frames on several parts of the input.
+func detectInterlace(file string, duration float64, conf Config) (ffmpeg.IdetStats, error) {
+	var stats ffmpeg.IdetStats
+	for _, w := range sampleWindows(duration, idetSamples, idetSampleDuration) {
+		cmd := ffmpeg.IdetCommand(conf.FFmpegPath, file, w.start, w.length)
+		if conf.Verbose {
+			fmt.Println(cmd)
+		}
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return ffmpeg.IdetStats{}, fmt.Errorf("was not able to detect interlacing: %w", err)
+		}
+		if s, ok := ffmpeg.ParseIdet(string(out)); ok {
+			stats = stats.Add(s)
+		}
+	}
+	return stats, nil
+}
+
+// interlaceStages decides the deinterlacing stages of the filter from
+// the settings and the detected frames. The detection is only used by
+// the auto settings.
+func interlaceStages(v VideoConf, stats ffmpeg.IdetStats) (ffmpeg.Deinterlacer, bool) {
+	ratio := stats.Interlaced()
+	telecined := ratio >= progressiveRatio && ratio < interlacedRatio
+	interlaced := ratio >= interlacedRatio
+
+	ivtc := v.InverseTelecine == SwitchOn || v.InverseTelecine == SwitchAuto && telecined
+	deinterlace := v.Deinterlace == SwitchOn || v.Deinterlace == SwitchAuto && interlaced
+	if !deinterlace {
+		return ffmpeg.DeinterlacerNone, ivtc
+	}
+	if v.Deinterlacer == ffmpeg.DeinterlacerNone {
+		return ffmpeg.DeinterlacerBwdif, ivtc
+	}
+	return v.Deinterlacer, ivtc
+}
@@ -0,0 +1,35 @@
+package burner
+
+import (
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"testing"
+)
+
+func TestInterlaceStages(t *testing.T) {
+	progressive := ffmpeg.IdetStats{TFF: 2, Progressive: 240}
+	telecined := ffmpeg.IdetStats{TFF: 96, Progressive: 141}
+	interlaced := ffmpeg.IdetStats{TFF: 230, Progressive: 10}
+	tests := []struct {
+		name      string
+		video     VideoConf
+		stats     ffmpeg.IdetStats
+		wantDeint ffmpeg.Deinterlacer
+		wantIVTC  bool
+	}{
+		{name: "off", video: VideoConf{}, stats: interlaced},
+		{name: "on", video: VideoConf{Deinterlace: SwitchOn}, stats: progressive, wantDeint: ffmpeg.DeinterlacerBwdif},
+		{name: "on with yadif", video: VideoConf{Deinterlace: SwitchOn, Deinterlacer: ffmpeg.DeinterlacerYadif}, wantDeint: ffmpeg.DeinterlacerYadif},
+		{name: "auto progressive", video: VideoConf{Deinterlace: SwitchAuto, InverseTelecine: SwitchAuto}, stats: progressive},
+		{name: "auto telecined", video: VideoConf{Deinterlace: SwitchAuto, InverseTelecine: SwitchAuto}, stats: telecined, wantIVTC: true},
+		{name: "auto interlaced", video: VideoConf{Deinterlace: SwitchAuto, InverseTelecine: SwitchAuto}, stats: interlaced, wantDeint: ffmpeg.DeinterlacerBwdif},
+		{name: "telecined without ivtc", video: VideoConf{Deinterlace: SwitchAuto}, stats: telecined},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deint, ivtc := interlaceStages(tt.video, tt.stats)
+			if deint != tt.wantDeint || ivtc != tt.wantIVTC {
+				t.Errorf("interlaceStages() = %v, %v, want %v, %v", deint, ivtc, tt.wantDeint, tt.wantIVTC)
+			}
+		})
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/shiroi-usagi/burner"
+	"github.com/shiroi-usagi/burner/commandline"
 	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/jobqueue"
+	"github.com/shiroi-usagi/burner/remote"
 	"github.com/spf13/cobra"
 	"golang.org/x/mod/semver"
 	"net/http"
@@ -29,6 +32,9 @@ func init() {
 
 var (
 	verbose = Cmd.Flags().BoolP("verbose", "v", false, "make output verbose")
+	dryRun  = Cmd.Flags().Bool("dry-run", false, "print the plan of the encoding without executing it")
+
+	emitScript = Cmd.Flags().String("emit-script", "", "write the encoding as a POSIX shell script instead of executing it, a file named Makefile or *.mk is written as a Makefile. The crop, interlace and loudness analyses run on this machine, the script holds their results")
 
 	mode = Cmd.Flags().StringP("mode", "m", "", `mode of the encoding
   smp4 - Sample MP4. Encodes a sample with the subtitle burned on the video. Creates hardsub.
@@ -40,13 +46,99 @@ var (
 	outputDir = Cmd.Flags().StringP("output", "o", "./out", "directory of the output files")
 
 	ignoreFontError = Cmd.Flags().Bool("ignore-font-error", false, "skip font errors during encode")
+	fontFallback    = Cmd.Flags().String("font-fallback", "", "font forced on the subtitle when a file is retried after a font error")
+	fontRetryIgnore = Cmd.Flags().Bool("font-retry-ignore", false, "retry a file stopped on a font error with font errors skipped, the output is flagged as degraded")
+
+	rulesFile = Cmd.Flags().String("rules", "", `JSON file of rules applied on the ffmpeg output, e.g.
+  [{"pattern": "Non-monotonous DTS", "action": "count", "message": "non-monotonous DTS"}]
+  actions: kill, warn, count, ignore`)
 
 	videoHeight      = Cmd.Flags().Int("v-height", burner.DefaultHeight, "target video height")
-	videoBitrate     = Cmd.Flags().String("v-bitrate", burner.DefaultBitrate, "target video bitrate")
-	videoKeepBitrate = Cmd.Flags().Bool("v-keep-bitrate", false, "disables bitrate modification when the original file size smaller than the expected")
+	videoBitrate     = bitrateFlag("v-bitrate", burner.DefaultBitrate, "target video bitrate, e.g. 1371k or 1.5M (k, M and G are powers of 1000, Ki, Mi and Gi of 1024)")
+	videoKeepBitrate = Cmd.Flags().Bool("v-keep-bitrate", false, "disables capping the bitrate at the video bitrate of the source")
 	videoUpscaling   = Cmd.Flags().Bool("v-upscaling", false, "enable/disable upscaling")
+	videoAutoCrop    = Cmd.Flags().Bool("v-autocrop", false, "detect the black bars of the inputs and crop them")
+	videoCrop        = Cmd.Flags().String("v-crop", "", "crop of the inputs as w:h:x:y, e.g. 1920:800:0:140, it overrides --v-autocrop")
+	videoSourceRatio = Cmd.Flags().Float64("v-source-ratio", 1, "cap the bitrate at this ratio of the video bitrate of the source")
+
+	videoDeinterlace  = Cmd.Flags().String("v-deinterlace", "off", "deinterlace the inputs, auto detects the interlaced inputs (auto, on, off)")
+	videoDeinterlacer = Cmd.Flags().String("v-deinterlacer", "bwdif", "filter of the deinterlacing (bwdif, yadif)")
+	videoIVTC         = Cmd.Flags().String("v-ivtc", "off", "inverse telecine of the inputs, auto detects the telecined inputs (auto, on, off)")
+
+	videoRateMode  = Cmd.Flags().String("v-fps-mode", "keep", "frame rate of the outputs: keep, cap at --v-fps, or cfr converts to a constant --v-fps (the input rate when it is not set)")
+	videoFrameRate = Cmd.Flags().String("v-fps", "", "frame rate of the --v-fps-mode, e.g. 30 or 24000/1001")
+
+	videoTonemap          = Cmd.Flags().String("v-tonemap", "auto", "map HDR inputs to SDR before the subtitle is rendered, auto detects the HDR inputs (auto, on, off)")
+	videoTonemapAlgorithm = Cmd.Flags().String("v-tonemap-algorithm", "hable", "algorithm of the tone mapping (none, clip, linear, gamma, reinhard, hable, mobius)")
+	videoTonemapPeak      = Cmd.Flags().Float64("v-tonemap-peak", 0, "peak brightness of the HDR signal relative to 100 nits, 0 reads it from the input")
+
+	videoSubtitleAfterScale = Cmd.Flags().Bool("v-subtitle-after-scale", false, "render the subtitle after the scale, the text is sharper on downscaled outputs")
+
+	videoCopyCompatible = Cmd.Flags().Bool("v-copy-compatible", false, "copy the video which already meets the target in the transcode mode (h264, yuv420p, height and bitrate), the file is remuxed")
+
+	watermark        = Cmd.Flags().String("watermark", "", "image drawn over the scaled frames of the outputs, e.g. the logo of the group")
+	watermarkCorner  = Cmd.Flags().String("watermark-corner", "bottom-right", "corner of the watermark (top-left, top-right, bottom-left, bottom-right)")
+	watermarkMargin  = Cmd.Flags().Int("watermark-margin", 16, "distance of the watermark from the edges in pixels")
+	watermarkOpacity = Cmd.Flags().Float64("watermark-opacity", 1, "opacity of the watermark between 0 and 1")
+	watermarkScale   = Cmd.Flags().Float64("watermark-scale", 0, "height of the watermark relative to the output height, 0 keeps the size of the image")
+	watermarkStart   = Cmd.Flags().Duration("watermark-start", 0, "show the watermark from this time of the input")
+	watermarkEnd     = Cmd.Flags().Duration("watermark-end", 0, "show the watermark until this time of the input, 0 shows it until the end")
+
+	intro = Cmd.Flags().StringSlice("intro", nil, "clips joined before every output in the mp4 and fmp4 modes, e.g. the intro of the group")
+	outro = Cmd.Flags().StringSlice("outro", nil, "clips joined after every output in the mp4 and fmp4 modes, e.g. a sponsor card")
+
+	subtitles = Cmd.Flags().String("subtitles", "burn", "subtitles of the hardsub modes: burn renders the subtitle, soft muxes the text subtitles into the mp4 (mov_text) and fmp4 (WebVTT) outputs, both or none")
+
+	audioBitrate = bitrateFlag("a-bitrate", burner.DefaultAudioBitrate, "target audio bitrate")
+
+	audioCodec          = Cmd.Flags().String("a-codec", "", "codec of the audio, the preset of the mode when it is not set (aac, libopus, ac3, copy)")
+	audioChannels       = Cmd.Flags().Int("a-channels", 0, "number of audio channels, 0 keeps the preset of the mode")
+	audioDownmix        = Cmd.Flags().String("a-downmix", "", "matrix of the pan filter applied to the audio, e.g. stereo|FL=FC+0.30*FL+0.30*BL|FR=FC+0.30*FR+0.30*BR")
+	audioSampleRate     = Cmd.Flags().Int("a-sample-rate", 0, "sample rate of the audio in Hz, 0 keeps the preset of the mode")
+	audioCopyCompatible = Cmd.Flags().Bool("a-copy-compatible", false, "copy the audio which is already encoded as the output, e.g. AAC stereo")
+
+	audioLoudnorm = Cmd.Flags().Bool("a-loudnorm", false, "normalize the loudness of the audio with the two-pass EBU R128 loudnorm")
+	audioLoudI    = Cmd.Flags().Float64("a-loudnorm-i", -23, "target integrated loudness of the normalization in LUFS")
+	audioLoudTP   = Cmd.Flags().Float64("a-loudnorm-tp", -1, "target true peak of the normalization in dBTP")
+	audioLoudLRA  = Cmd.Flags().Float64("a-loudnorm-lra", 7, "target loudness range of the normalization in LU")
+
+	targetSize = Cmd.Flags().String("target-size", "", "highest size of an output, e.g. 350M, the video bitrate is derived from it (k, M and G are powers of 1000, Ki, Mi and Gi of 1024)")
+
+	videoTargetVMAF     = Cmd.Flags().Float64("v-target-vmaf", 0, "search the lowest bitrate up to --v-bitrate of each file which meets the VMAF score")
+	videoMinBitrate     = bitrateFlag("v-min-bitrate", 300*ffmpeg.Kilobit, "lowest bitrate of the target VMAF search")
+	videoSearchSamples  = Cmd.Flags().Int("v-search-samples", 3, "number of samples encoded at every bitrate of the target VMAF search")
+	videoSearchDuration = Cmd.Flags().Duration("v-search-sample-duration", 20*time.Second, "length of the samples of the target VMAF search")
+	videoSearchSteps    = Cmd.Flags().Int("v-search-steps", 5, "highest number of bitrates tried by the target VMAF search")
+
+	chunks        = Cmd.Flags().Int("chunks", 0, "split long files into the given number of chunks encoded in parallel (mp4 mode)")
+	chunkDuration = Cmd.Flags().Duration("chunk-min-duration", 30*time.Minute, "shortest file which is split into chunks")
+
+	qualityMetrics = Cmd.Flags().StringSlice("quality", nil, "quality metrics measured against the source after the encode, comma separated list of vmaf, ssim and psnr")
+	qualityMinVMAF = Cmd.Flags().Float64("quality-min-vmaf", 0, "lowest accepted VMAF score")
+	qualityMinSSIM = Cmd.Flags().Float64("quality-min-ssim", 0, "lowest accepted SSIM")
+	qualityMinPSNR = Cmd.Flags().Float64("quality-min-psnr", 0, "lowest accepted PSNR in dB")
+	qualityFail    = Cmd.Flags().Bool("quality-fail", false, "fail the files below a quality threshold instead of warning")
+
+	reportFile = Cmd.Flags().String("report", "", "path of the JSON batch report (default \"<output>/report.json\")")
+	junitFile  = Cmd.Flags().String("junit", "", "path of the JUnit XML batch report")
+
+	workers      = Cmd.Flags().StringArray("worker", nil, "encode on the given SSH worker instead of this machine, in [user@]host[:port] form, can be repeated")
+	workerDir    = Cmd.Flags().String("worker-dir", "/tmp/burner", "directory on the workers which holds the files of the jobs")
+	workerFFmpeg = Cmd.Flags().String("worker-ffmpeg", "ffmpeg", "ffmpeg executable on the workers")
+
+	queueFile    = Cmd.Flags().String("queue", "", "journal of the job queue (default \"<output>/queue.jsonl\")")
+	retries      = Cmd.Flags().Int("retries", 0, "number of retries of a failed file")
+	retryBackoff = Cmd.Flags().Duration("retry-backoff", 30*time.Second, "wait before the first retry, doubles on every further retry")
+	retryFailed  = Cmd.Flags().Bool("retry-failed", false, "encode the files which failed in a previous run again, the finished files are skipped")
 )
 
+// bitrateFlag defines a bitrate flag which is validated at parsing.
+func bitrateFlag(name string, value ffmpeg.Bitrate, usage string) *ffmpeg.Bitrate {
+	b := value
+	Cmd.Flags().Var(&b, name, usage)
+	return &b
+}
+
 func run(_ *cobra.Command, args []string) {
 	absIn, err := filepath.Abs(*inputDir)
 	if err != nil {
@@ -56,6 +148,38 @@ func run(_ *cobra.Command, args []string) {
 	if err != nil {
 		fmt.Println("Could not create absolute representation of output folder")
 	}
+	absQueue := filepath.Join(absOut, "queue.jsonl")
+	if *queueFile != "" {
+		absQueue, err = filepath.Abs(*queueFile)
+		if err != nil {
+			fmt.Println("Could not create absolute representation of queue file")
+		}
+	}
+	absReport := filepath.Join(absOut, "report.json")
+	if *reportFile != "" {
+		absReport, err = filepath.Abs(*reportFile)
+		if err != nil {
+			fmt.Println("Could not create absolute representation of report file")
+		}
+	}
+	absJUnit := *junitFile
+	if absJUnit != "" {
+		absJUnit, err = filepath.Abs(absJUnit)
+		if err != nil {
+			fmt.Println("Could not create absolute representation of JUnit file")
+		}
+	}
+	if *dryRun && *emitScript != "" {
+		fmt.Println("--dry-run can not be combined with --emit-script, the script measures the inputs")
+		os.Exit(1)
+	}
+	absScript := *emitScript
+	if absScript != "" {
+		absScript, err = filepath.Abs(absScript)
+		if err != nil {
+			fmt.Println("Could not create absolute representation of script file")
+		}
+	}
 	ffmpegExecutable, err := exec.LookPath("ffmpeg")
 	if err != nil {
 		fmt.Println("ffmpeg is not found in path, will try fallback")
@@ -72,6 +196,162 @@ func run(_ *cobra.Command, args []string) {
 			fmt.Println(err)
 		}
 	}
+	var ws []burner.Worker
+	for _, worker := range *workers {
+		t, err := remote.ParseSSH(worker)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		ws = append(ws, burner.Worker{Name: t.String(), Transport: t, WorkDir: *workerDir, FFmpegPath: *workerFFmpeg})
+	}
+	var size int64
+	if *targetSize != "" {
+		size, err = burner.ParseSize(*targetSize)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	var crop ffmpeg.Crop
+	if *videoCrop != "" {
+		crop, err = ffmpeg.ParseCrop(*videoCrop)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	deinterlace, err := burner.ParseSwitch(*videoDeinterlace)
+	if err != nil {
+		fmt.Printf("--v-deinterlace: %s\n", err)
+		os.Exit(1)
+	}
+	deinterlacer, err := ffmpeg.ParseDeinterlacer(*videoDeinterlacer)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	ivtc, err := burner.ParseSwitch(*videoIVTC)
+	if err != nil {
+		fmt.Printf("--v-ivtc: %s\n", err)
+		os.Exit(1)
+	}
+	loudness := ffmpeg.LoudnessTarget{I: *audioLoudI, TP: *audioLoudTP, LRA: *audioLoudLRA}
+	if *audioLoudnorm {
+		if err := loudness.Validate(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	var codec string
+	if *audioCodec != "" {
+		codec, err = ffmpeg.ParseAudioCodec(*audioCodec)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if codec == ffmpeg.AudioCodecCopy && (*audioLoudnorm || *audioDownmix != "" || *audioChannels > 0 || *audioSampleRate > 0) {
+		fmt.Println("--a-codec: copy can not be combined with --a-loudnorm, --a-downmix, --a-channels or --a-sample-rate")
+		os.Exit(1)
+	}
+	if codec == ffmpeg.AudioCodecCopy && len(*intro)+len(*outro) > 0 {
+		fmt.Println("--a-codec: copy can not be combined with --intro or --outro")
+		os.Exit(1)
+	}
+	if *audioChannels < 0 || *audioSampleRate < 0 {
+		fmt.Println("--a-channels and --a-sample-rate can not be negative")
+		os.Exit(1)
+	}
+	subtitleMode, err := burner.ParseSubtitleMode(*subtitles)
+	if err != nil {
+		fmt.Printf("--subtitles: %s\n", err)
+		os.Exit(1)
+	}
+	rateMode, err := burner.ParseRateMode(*videoRateMode)
+	if err != nil {
+		fmt.Printf("--v-fps-mode: %s\n", err)
+		os.Exit(1)
+	}
+	var frameRate ffmpeg.FrameRate
+	if *videoFrameRate != "" {
+		frameRate, err = ffmpeg.ParseFrameRate(*videoFrameRate)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if rateMode == burner.RateCap && frameRate.IsZero() {
+		fmt.Println("--v-fps-mode: cap needs the frame rate of --v-fps")
+		os.Exit(1)
+	}
+	tonemap, err := burner.ParseSwitch(*videoTonemap)
+	if err != nil {
+		fmt.Printf("--v-tonemap: %s\n", err)
+		os.Exit(1)
+	}
+	tonemapAlgorithm, err := ffmpeg.ParseTonemapAlgorithm(*videoTonemapAlgorithm)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	var wm ffmpeg.Watermark
+	if *watermark != "" {
+		corner, err := ffmpeg.ParseCorner(*watermarkCorner)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		image, err := filepath.Abs(*watermark)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if _, err := os.Stat(image); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		wm = ffmpeg.Watermark{
+			Image:   image,
+			Corner:  corner,
+			Margin:  *watermarkMargin,
+			Opacity: *watermarkOpacity,
+			Scale:   *watermarkScale,
+			Start:   *watermarkStart,
+			End:     *watermarkEnd,
+		}
+		if err := wm.Validate(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	introClips, err := existingFiles(*intro)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	outroClips, err := existingFiles(*outro)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	var metrics []ffmpeg.Metric
+	for _, name := range *qualityMetrics {
+		m, err := ffmpeg.ParseMetric(name)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		metrics = append(metrics, m)
+	}
+	var rules commandline.RuleSet
+	if *rulesFile != "" {
+		rules, err = commandline.LoadRules(*rulesFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
 	currentV := burner.GetVersionInfo().Version
 	fmt.Println(fmt.Sprintf("Current version: `%s`", currentV))
 	latestV, err := latestVersion()
@@ -93,9 +373,45 @@ func run(_ *cobra.Command, args []string) {
 		}
 		selectedMode = burner.ReadMode(reader)
 	}
+	if *audioLoudnorm && selectedMode == burner.ModeTranscode {
+		fmt.Println("--a-loudnorm: the transcode mode keeps every audio stream, their loudness can not be normalized")
+		os.Exit(1)
+	}
+	if *emitScript != "" && selectedMode == burner.ModeFragmentedMP4 && subtitleMode.Muxes() {
+		fmt.Println("--emit-script: the WebVTT renditions of --subtitles soft or both can not be scripted")
+		os.Exit(1)
+	}
 
 	burner.Burn(burner.Config{
 		Verbose: *verbose,
+		DryRun:  *dryRun,
+
+		EmitScript: absScript,
+
+		Workers: ws,
+
+		TargetSize: size,
+
+		Bumpers: burner.BumperConf{
+			Intro: introClips,
+			Outro: outroClips,
+		},
+		Subtitles: subtitleMode,
+
+		Chunks: burner.ChunkConf{
+			Count:       *chunks,
+			MinDuration: *chunkDuration,
+		},
+
+		Quality: burner.QualityConf{
+			Metrics: metrics,
+			Min: ffmpeg.Scores{
+				VMAF: *qualityMinVMAF,
+				SSIM: *qualityMinSSIM,
+				PSNR: *qualityMinPSNR,
+			},
+			Fail: *qualityFail,
+		},
 
 		Mode: selectedMode,
 
@@ -105,16 +421,85 @@ func run(_ *cobra.Command, args []string) {
 		FFprobePath: ffprobeExecutable,
 
 		IgnoreFontError: *ignoreFontError,
+		Rules:           rules,
+		FontRetry: burner.FontRetryConf{
+			FallbackFont: *fontFallback,
+			IgnoreErrors: *fontRetryIgnore,
+		},
+
+		ReportFile: absReport,
+		JUnitFile:  absJUnit,
+
+		QueueFile: absQueue,
+		Retry: jobqueue.RetryPolicy{
+			MaxAttempts: *retries + 1,
+			Backoff:     *retryBackoff,
+			MaxBackoff:  time.Hour,
+		},
+		RetryFailed: *retryFailed,
+
+		Audio: burner.AudioConf{
+			Bitrate:  *audioBitrate,
+			Loudnorm: *audioLoudnorm,
+			Loudness: loudness,
+
+			Codec:          codec,
+			Channels:       *audioChannels,
+			SampleRate:     *audioSampleRate,
+			Downmix:        *audioDownmix,
+			CopyCompatible: *audioCopyCompatible,
+		},
 
 		Video: burner.VideoConf{
 			Height:      *videoHeight,
 			Bitrate:     *videoBitrate,
 			KeepBitrate: *videoKeepBitrate,
 			Upscaling:   *videoUpscaling,
+			SourceRatio: *videoSourceRatio,
+			AutoCrop:    *videoAutoCrop,
+			Crop:        crop,
+
+			Deinterlace:     deinterlace,
+			Deinterlacer:    deinterlacer,
+			InverseTelecine: ivtc,
+
+			RateMode:  rateMode,
+			FrameRate: frameRate,
+
+			Tonemap:          tonemap,
+			TonemapAlgorithm: tonemapAlgorithm,
+			TonemapPeak:      *videoTonemapPeak,
+
+			SubtitleAfterScale: *videoSubtitleAfterScale,
+			Watermark:          wm,
+			CopyCompatible:     *videoCopyCompatible,
+			Search: burner.SearchConf{
+				TargetVMAF:     *videoTargetVMAF,
+				MinBitrate:     *videoMinBitrate,
+				Samples:        *videoSearchSamples,
+				SampleDuration: *videoSearchDuration,
+				Steps:          *videoSearchSteps,
+			},
 		},
 	})
 }
 
+// existingFiles makes the paths absolute and checks that the files exist.
+func existingFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(abs); err != nil {
+			return nil, err
+		}
+		files = append(files, abs)
+	}
+	return files, nil
+}
+
 type releasePayload struct {
 	TagName string `json:"tag_name"`
 }
@@ -0,0 +1,192 @@
+package queue
+
+import (
+	"fmt"
+	"github.com/shiroi-usagi/burner"
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/jobqueue"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "queue",
+	Short: "manage the job queue",
+	Long: `Manage the persistent job queue used by burn. Jobs with
+higher priority are encoded first.`,
+}
+
+var (
+	queueFile = Cmd.PersistentFlags().StringP("queue", "q", filepath.Join(".", "out", "queue.jsonl"), "journal of the job queue")
+
+	addCmd = &cobra.Command{
+		Use:   "add file...",
+		Short: "add files to the queue",
+		Args:  cobra.MinimumNArgs(1),
+	}
+	listCmd = &cobra.Command{
+		Use:   "list",
+		Short: "list the jobs of the queue",
+		Args:  cobra.NoArgs,
+	}
+	removeCmd = &cobra.Command{
+		Use:   "remove id...",
+		Short: "remove jobs from the queue",
+		Args:  cobra.MinimumNArgs(1),
+	}
+	retryCmd = &cobra.Command{
+		Use:   "retry [id...]",
+		Short: "return failed jobs to pending, every failed job without ids",
+		Args:  cobra.ArbitraryArgs,
+	}
+	reprioritizeCmd = &cobra.Command{
+		Use:   "reprioritize id priority",
+		Short: "change the priority of a job",
+		Args:  cobra.ExactArgs(2),
+	}
+
+	priority = addCmd.Flags().IntP("priority", "p", 0, "priority of the jobs")
+	mode     = addCmd.Flags().StringP("mode", "m", "", "mode of the encoding, overrides the mode of burn")
+	bitrate  = new(ffmpeg.Bitrate)
+	height   = addCmd.Flags().Int("v-height", 0, "target video height, overrides the height of burn")
+	crop     = addCmd.Flags().String("crop", "", "crop of the video, auto, none or a w:h:x:y rectangle, overrides the crop of burn")
+
+	subtitles = addCmd.Flags().String("subtitles", "", "subtitles of the jobs, burn, soft, both or none, overrides the subtitles of burn")
+)
+
+func init() {
+	addCmd.Flags().Var(bitrate, "v-bitrate", "target video bitrate, overrides the bitrate of burn")
+	// break init cycle
+	addCmd.RunE = add
+	listCmd.RunE = list
+	removeCmd.RunE = remove
+	retryCmd.RunE = retry
+	reprioritizeCmd.RunE = reprioritize
+	Cmd.AddCommand(addCmd, listCmd, removeCmd, retryCmd, reprioritizeCmd)
+}
+
+func open() (*jobqueue.Queue, error) {
+	return jobqueue.Open(*queueFile)
+}
+
+func add(_ *cobra.Command, args []string) error {
+	if *mode != "" && burner.StringToMode(*mode) == burner.ModeNone {
+		return fmt.Errorf("unknown mode `%s`", *mode)
+	}
+	if err := burner.ValidateCrop(*crop); err != nil {
+		return err
+	}
+	var subtitleMode burner.SubtitleMode
+	if *subtitles != "" {
+		m, err := burner.ParseSubtitleMode(*subtitles)
+		if err != nil {
+			return err
+		}
+		subtitleMode = m
+	}
+	var videoBitrate string
+	if *bitrate != 0 {
+		videoBitrate = bitrate.String()
+	}
+	q, err := open()
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+	for _, arg := range args {
+		abs, err := filepath.Abs(arg)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(abs); err != nil {
+			return err
+		}
+		j, err := q.Add(abs, *priority, jobqueue.Settings{Mode: *mode, Bitrate: videoBitrate, Height: *height, Crop: *crop, Subtitles: string(subtitleMode)})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("added job %d\n", j.ID)
+	}
+	return nil
+}
+
+func list(_ *cobra.Command, _ []string) error {
+	q, err := open()
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPRIORITY\tSTATUS\tATTEMPTS\tINPUT\tERROR")
+	for _, j := range q.List() {
+		fmt.Fprintf(w, "%d\t%d\t%s\t%d\t%s\t%s\n", j.ID, j.Priority, j.Status, j.Attempts, j.Input, j.LastError)
+	}
+	return w.Flush()
+}
+
+func remove(_ *cobra.Command, args []string) error {
+	q, err := open()
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+	for _, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("invalid job id `%s`", arg)
+		}
+		if err := q.Remove(id); err != nil {
+			return fmt.Errorf("job %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+func retry(_ *cobra.Command, args []string) error {
+	q, err := open()
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+	if len(args) == 0 {
+		for _, j := range q.List() {
+			if j.Status == jobqueue.StatusFailed {
+				args = append(args, strconv.Itoa(j.ID))
+			}
+		}
+	}
+	for _, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("invalid job id `%s`", arg)
+		}
+		if err := q.Retry(id); err != nil {
+			return fmt.Errorf("job %d: %w", id, err)
+		}
+		fmt.Printf("job %d is pending\n", id)
+	}
+	return nil
+}
+
+func reprioritize(_ *cobra.Command, args []string) error {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid job id `%s`", args[0])
+	}
+	p, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid priority `%s`", args[1])
+	}
+	q, err := open()
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+	if err := q.Reprioritize(id, p); err != nil {
+		return fmt.Errorf("job %d: %w", id, err)
+	}
+	return nil
+}
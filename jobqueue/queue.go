@@ -0,0 +1,412 @@
+package jobqueue
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownJob = errors.New("unknown job")
+	ErrNotFailed  = errors.New("job has not failed")
+)
+
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+)
+
+// Settings overrides the batch configuration for a single job.
+//
+// The zero value of a field means the batch configuration is used.
+type Settings struct {
+	Mode string `json:"mode,omitempty"`
+	// Bitrate is the video bitrate, e.g. 1371k.
+	Bitrate string `json:"bitrate,omitempty"`
+	Height  int    `json:"height,omitempty"`
+	// Crop is auto, none or a w:h:x:y rectangle.
+	Crop string `json:"crop,omitempty"`
+	// Subtitles is burn, soft, both or none.
+	Subtitles string `json:"subtitles,omitempty"`
+}
+
+type Job struct {
+	ID       int      `json:"id"`
+	Input    string   `json:"input"`
+	Priority int      `json:"priority"`
+	Settings Settings `json:"settings"`
+
+	Status      Status    `json:"status"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+	Added       time.Time `json:"added"`
+}
+
+// RetryPolicy describes how many times a failed job is retried and
+// how long the queue waits before the next attempt.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts before a job is marked failed.
+	// Values below one are treated as one.
+	MaxAttempts int
+	// Backoff is the wait before the first retry, it doubles on every further attempt.
+	Backoff time.Duration
+	// MaxBackoff caps the wait between two attempts, zero means no limit.
+	MaxBackoff time.Duration
+}
+
+// Delay returns the wait before the given attempt.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return d
+}
+
+type op string
+
+const (
+	opPut    op = "put"
+	opRemove op = "remove"
+)
+
+// record is a single line of the journal.
+type record struct {
+	Op  op   `json:"op"`
+	Job *Job `json:"job,omitempty"`
+	ID  int  `json:"id,omitempty"`
+}
+
+// Queue is a priority queue of jobs persisted as a JSON-lines journal.
+//
+// Every modification is appended to the journal before it is visible,
+// so the queue can be restored after a crash by replaying the journal.
+type Queue struct {
+	mu     sync.Mutex
+	path   string
+	f      *os.File
+	jobs   map[int]*Job
+	nextID int
+	now    func() time.Time
+}
+
+// Open restores the queue from the journal at path, creating it when
+// it does not exist. Jobs left running by a previous process are
+// returned to pending.
+//
+// An empty path opens a queue which only lives in memory.
+func Open(path string) (*Queue, error) {
+	q, err := Load(path)
+	if err != nil || path == "" {
+		return q, err
+	}
+	if err := q.compact(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Load restores the queue from the journal at path into memory.
+// Modifications of the loaded queue are not persisted.
+func Load(path string) (*Queue, error) {
+	q := &Queue{path: path, jobs: map[int]*Job{}, nextID: 1, now: time.Now}
+	if err := q.replay(); err != nil {
+		return nil, err
+	}
+	for _, j := range q.jobs {
+		if j.Status == StatusRunning {
+			j.Status = StatusPending
+		}
+	}
+	return q, nil
+}
+
+func (q *Queue) replay() error {
+	if q.path == "" {
+		return nil
+	}
+	f, err := os.Open(q.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1024*1024)
+	for n := 1; s.Scan(); n++ {
+		var r record
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			// A crash in the middle of a write can only corrupt the last line
+			return fmt.Errorf("jobqueue: corrupt journal %s at line %d: %w", q.path, n, err)
+		}
+		q.apply(r)
+	}
+	return s.Err()
+}
+
+func (q *Queue) apply(r record) {
+	switch r.Op {
+	case opPut:
+		q.jobs[r.Job.ID] = r.Job
+		if r.Job.ID >= q.nextID {
+			q.nextID = r.Job.ID + 1
+		}
+	case opRemove:
+		delete(q.jobs, r.ID)
+	}
+}
+
+// compact rewrites the journal with one record per job.
+func (q *Queue) compact() error {
+	tmp := q.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, j := range q.sorted() {
+		if err := enc.Encode(record{Op: opPut, Job: j}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, q.path); err != nil {
+		return err
+	}
+	q.f, err = os.OpenFile(q.path, os.O_WRONLY|os.O_APPEND, 0644)
+	return err
+}
+
+func (q *Queue) write(r record) error {
+	if q.f != nil {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		if _, err := q.f.Write(append(b, '\n')); err != nil {
+			return err
+		}
+		if err := q.f.Sync(); err != nil {
+			return err
+		}
+	}
+	q.apply(r)
+	return nil
+}
+
+func (q *Queue) put(j Job) error {
+	return q.write(record{Op: opPut, Job: &j})
+}
+
+// Close closes the underlying journal.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.f == nil {
+		return nil
+	}
+	err := q.f.Close()
+	q.f = nil
+	return err
+}
+
+// Add appends a new pending job to the queue.
+func (q *Queue) Add(input string, priority int, s Settings) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.add(input, priority, s)
+}
+
+// add appends a pending job, q.mu must be held.
+func (q *Queue) add(input string, priority int, s Settings) (Job, error) {
+	j := Job{
+		ID:       q.nextID,
+		Input:    input,
+		Priority: priority,
+		Settings: s,
+		Status:   StatusPending,
+		Added:    q.now().UTC(),
+	}
+	return j, q.put(j)
+}
+
+// Get returns the job with the given id.
+func (q *Queue) Get(id int) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return Job{}, ErrUnknownJob
+	}
+	return *j, nil
+}
+
+// Remove deletes the job with the given id from the queue.
+func (q *Queue) Remove(id int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.jobs[id]; !ok {
+		return ErrUnknownJob
+	}
+	return q.write(record{Op: opRemove, ID: id})
+}
+
+// Reprioritize changes the priority of the job with the given id.
+func (q *Queue) Reprioritize(id, priority int) error {
+	return q.update(id, func(j *Job) {
+		j.Priority = priority
+	})
+}
+
+// List returns all jobs in the order they would be processed.
+func (q *Queue) List() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var jobs []Job
+	for _, j := range q.sorted() {
+		jobs = append(jobs, *j)
+	}
+	return jobs
+}
+
+// sorted orders the jobs by descending priority then by insertion.
+func (q *Queue) sorted() []*Job {
+	jobs := make([]*Job, 0, len(q.jobs))
+	for _, j := range q.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(i, k int) bool {
+		if jobs[i].Priority != jobs[k].Priority {
+			return jobs[i].Priority > jobs[k].Priority
+		}
+		return jobs[i].ID < jobs[k].ID
+	})
+	return jobs
+}
+
+// Next marks the pending job with the highest priority as running
+// and returns it.
+//
+// When no job is ready but some are waiting for a retry, wait is the
+// time until the earliest of them becomes ready. The error of the
+// journal is returned when the job can not be marked.
+func (q *Queue) Next() (j Job, wait time.Duration, ok bool, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.now()
+	wait = -1
+	for _, c := range q.sorted() {
+		if c.Status != StatusPending {
+			continue
+		}
+		if d := c.NextAttempt.Sub(now); d > 0 {
+			if wait < 0 || d < wait {
+				wait = d
+			}
+			continue
+		}
+		j = *c
+		j.Status = StatusRunning
+		j.Attempts++
+		if err := q.put(j); err != nil {
+			return Job{}, 0, false, err
+		}
+		return j, 0, true, nil
+	}
+	return Job{}, wait, false, nil
+}
+
+// Done marks the job as successfully finished.
+func (q *Queue) Done(id int) error {
+	return q.update(id, func(j *Job) {
+		j.Status = StatusDone
+		j.LastError = ""
+	})
+}
+
+// Fail records the error of the last attempt. The job returns to
+// pending with a backoff until the policy runs out of attempts.
+func (q *Queue) Fail(id int, cause error, p RetryPolicy) error {
+	now := q.now()
+	return q.update(id, func(j *Job) {
+		j.LastError = cause.Error()
+		if j.Attempts >= p.MaxAttempts {
+			j.Status = StatusFailed
+			return
+		}
+		j.Status = StatusPending
+		j.NextAttempt = now.Add(p.Delay(j.Attempts)).UTC()
+	})
+}
+
+// Enqueue adds input as a new job unless the queue already holds a job
+// of it, the job of input is returned and added reports whether it is
+// new. Finished jobs are not encoded again, failed jobs are returned to
+// pending by Retry.
+func (q *Queue) Enqueue(input string) (j Job, added bool, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, c := range q.jobs {
+		if c.Input == input {
+			return *c, false, nil
+		}
+	}
+	j, err = q.add(input, 0, Settings{})
+	return j, err == nil, err
+}
+
+// Retry returns the failed job with the given id to pending with its
+// attempts reset, the priority and the settings of the job are kept.
+func (q *Queue) Retry(id int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	c, ok := q.jobs[id]
+	if !ok {
+		return ErrUnknownJob
+	}
+	if c.Status != StatusFailed {
+		return ErrNotFailed
+	}
+	j := *c
+	j.Status = StatusPending
+	j.Attempts = 0
+	j.NextAttempt = time.Time{}
+	return q.put(j)
+}
+
+func (q *Queue) update(id int, fn func(j *Job)) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	c, ok := q.jobs[id]
+	if !ok {
+		return ErrUnknownJob
+	}
+	j := *c
+	fn(&j)
+	return q.put(j)
+}
@@ -0,0 +1,196 @@
+package jobqueue
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{
+			name:    "first retry",
+			policy:  RetryPolicy{Backoff: time.Second},
+			attempt: 1,
+			want:    time.Second,
+		},
+		{
+			name:    "doubles",
+			policy:  RetryPolicy{Backoff: time.Second},
+			attempt: 3,
+			want:    4 * time.Second,
+		},
+		{
+			name:    "capped",
+			policy:  RetryPolicy{Backoff: time.Second, MaxBackoff: 3 * time.Second},
+			attempt: 3,
+			want:    3 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Delay(tt.attempt); got != tt.want {
+				t.Errorf("Delay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueue_Next(t *testing.T) {
+	q, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	low, _ := q.Add("low", 0, Settings{})
+	high, _ := q.Add("high", 10, Settings{})
+
+	j, _, ok, _ := q.Next()
+	if !ok || j.ID != high.ID {
+		t.Fatalf("Next() = %v, want job %d", j.ID, high.ID)
+	}
+	if j.Status != StatusRunning || j.Attempts != 1 {
+		t.Errorf("Next() status = %s attempts = %d, want running attempts 1", j.Status, j.Attempts)
+	}
+	j, _, ok, _ = q.Next()
+	if !ok || j.ID != low.ID {
+		t.Fatalf("Next() = %v, want job %d", j.ID, low.ID)
+	}
+	if _, wait, ok, _ := q.Next(); ok || wait >= 0 {
+		t.Errorf("Next() on empty queue = %v, %v, want no job and no wait", ok, wait)
+	}
+}
+
+func TestQueue_Fail(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	q, _ := Open("")
+	q.now = func() time.Time { return now }
+	p := RetryPolicy{MaxAttempts: 2, Backoff: time.Minute}
+	added, _ := q.Add("file", 0, Settings{})
+
+	j, _, _, _ := q.Next()
+	if err := q.Fail(j.ID, errors.New("first"), p); err != nil {
+		t.Fatal(err)
+	}
+	if _, wait, ok, _ := q.Next(); ok || wait != time.Minute {
+		t.Fatalf("Next() during backoff = %v, %v, want no job and wait %v", ok, wait, time.Minute)
+	}
+
+	now = now.Add(time.Minute)
+	j, _, ok, _ := q.Next()
+	if !ok || j.Attempts != 2 {
+		t.Fatalf("Next() after backoff = %v attempts %d, want job with attempts 2", ok, j.Attempts)
+	}
+	if err := q.Fail(j.ID, errors.New("second"), p); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := q.Get(added.ID)
+	if got.Status != StatusFailed || got.LastError != "second" {
+		t.Errorf("Fail() status = %s error = %s, want failed with second", got.Status, got.LastError)
+	}
+}
+
+func TestOpen_replay(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "TestOpen_replay")
+	if err != nil {
+		t.Fatal("creating temp dir:", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "queue.jsonl")
+
+	q, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := q.Add("a", 0, Settings{Mode: "mp4"})
+	b, _ := q.Add("b", 0, Settings{})
+	c, _ := q.Add("c", 0, Settings{})
+	_ = q.Reprioritize(c.ID, 5)
+	_ = q.Remove(b.ID)
+	_, _, _, _ = q.Next() // c is running when the process "crashes"
+	_ = q.Close()
+
+	q, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	jobs := q.List()
+	if len(jobs) != 2 {
+		t.Fatalf("List() = %v, want 2 jobs", jobs)
+	}
+	if jobs[0].ID != c.ID || jobs[0].Priority != 5 || jobs[0].Status != StatusPending {
+		t.Errorf("List()[0] = %+v, want pending job %d with priority 5", jobs[0], c.ID)
+	}
+	if jobs[1].ID != a.ID || jobs[1].Settings.Mode != "mp4" {
+		t.Errorf("List()[1] = %+v, want job %d with mode mp4", jobs[1], a.ID)
+	}
+	if d, _ := q.Add("d", 0, Settings{}); d.ID <= c.ID {
+		t.Errorf("Add() id = %d, want greater than %d", d.ID, c.ID)
+	}
+}
+
+func TestQueue_Enqueue(t *testing.T) {
+	q, _ := Open("")
+	if _, added, _ := q.Enqueue("file"); !added {
+		t.Error("Enqueue() added = false, want true")
+	}
+	if _, added, _ := q.Enqueue("file"); added {
+		t.Error("Enqueue() twice added = true, want false")
+	}
+	if jobs := q.List(); len(jobs) != 1 {
+		t.Fatalf("Enqueue() twice = %v, want 1 job", jobs)
+	}
+	j, _, _, _ := q.Next()
+	_ = q.Done(j.ID)
+	got, added, _ := q.Enqueue("file")
+	jobs := q.List()
+	if len(jobs) != 1 || jobs[0].Status != StatusDone || added || got.Status != StatusDone {
+		t.Errorf("Enqueue() after done = %v, want 1 done job", jobs)
+	}
+}
+
+func TestQueue_Enqueue_concurrent(t *testing.T) {
+	q, _ := Open("")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, _ = q.Enqueue("file")
+		}()
+	}
+	wg.Wait()
+	if jobs := q.List(); len(jobs) != 1 {
+		t.Errorf("concurrent Enqueue() = %v, want 1 job", jobs)
+	}
+}
+
+func TestQueue_Retry(t *testing.T) {
+	q, _ := Open("")
+	added, _ := q.Add("file", 5, Settings{Mode: "mp4"})
+	if err := q.Retry(added.ID); !errors.Is(err, ErrNotFailed) {
+		t.Errorf("Retry() of pending job = %v, want %v", err, ErrNotFailed)
+	}
+	j, _, _, _ := q.Next()
+	_ = q.Fail(j.ID, errors.New("failed"), RetryPolicy{MaxAttempts: 1})
+	_, _, _ = q.Enqueue("file")
+	if jobs := q.List(); len(jobs) != 1 || jobs[0].Status != StatusFailed {
+		t.Fatalf("Enqueue() after failed = %v, want 1 failed job", jobs)
+	}
+	if err := q.Retry(added.ID); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := q.Get(added.ID)
+	if got.Status != StatusPending || got.Attempts != 0 || got.Priority != 5 || got.Settings.Mode != "mp4" {
+		t.Errorf("Retry() = %+v, want pending job with priority 5 and mode mp4", got)
+	}
+}
@@ -0,0 +1,44 @@
+package burner
+
+import (
+	"fmt"
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/ffprobe"
+	"github.com/shiroi-usagi/burner/report"
+	"log"
+)
+
+// planLoudnorm measures the loudness of the audio of file, which is
+// normalized to the target in the second pass. Audio which can not be
+// measured, e.g. silence, is kept with a warning.
+func planLoudnorm(file string, f ffmpeg.Filter, streams []ffprobe.Stream, conf Config, e *report.Entry) (ffmpeg.Filter, error) {
+	// The encode selects the same stream without a mapping
+	stream := f.AudioInput
+	if stream == "" && conf.FFprobePath != "" {
+		s, ok := defaultAudio(streams)
+		if !ok {
+			return f, nil
+		}
+		stream = fmt.Sprintf("0:%d", s.Index)
+	}
+
+	cmd := ffmpeg.LoudnormCommand(conf.FFmpegPath, file, stream, f.Downmix, conf.Audio.Loudness)
+	if conf.Verbose {
+		fmt.Println(cmd)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return f, fmt.Errorf("was not able to measure loudness: %w", err)
+	}
+	measured, ok := ffmpeg.ParseLoudnorm(string(out))
+	if !ok {
+		msg := "loudness was not measured, the audio is not normalized"
+		log.Print(msg)
+		e.Warnings = append(e.Warnings, msg)
+		return f, nil
+	}
+	log.Printf("loudness is %.2f LUFS, it is normalized to %g LUFS", measured.InputI, conf.Audio.Loudness.I)
+	e.Loudness = &measured
+	f.Loudnorm = ffmpeg.Loudnorm{Target: conf.Audio.Loudness, Measured: measured}
+	return f, nil
+}
@@ -43,6 +43,16 @@ func (m Mode) Label() string {
 	return labels[m]
 }
 
+// Name is the flag representation of m
+func (m Mode) Name() string {
+	for k, v := range flags {
+		if v == m {
+			return k
+		}
+	}
+	return ""
+}
+
 // StringToMode recognises a string representation of
 // modes.
 //
@@ -35,6 +35,17 @@ func TestStringToMode(t *testing.T) {
 	}
 }
 
+func TestMode_Name(t *testing.T) {
+	for name, m := range flags {
+		if got := m.Name(); got != name {
+			t.Errorf("Name() = %v, want %v", got, name)
+		}
+	}
+	if got := ModeNone.Name(); got != "" {
+		t.Errorf("Name() = %v, want empty", got)
+	}
+}
+
 func TestReadMode(t *testing.T) {
 	type args struct {
 		reader io.RuneReader
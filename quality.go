@@ -0,0 +1,115 @@
+package burner
+
+import (
+	"errors"
+	"fmt"
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/report"
+	"log"
+	"strings"
+)
+
+// QualityConf enables measuring the quality of the outputs against
+// their source.
+type QualityConf struct {
+	// Metrics are measured after the encode, empty disables measuring.
+	Metrics []ffmpeg.Metric
+	// Min are the lowest accepted scores, zero disables the threshold.
+	Min ffmpeg.Scores
+	// Fail fails the file below a threshold instead of warning.
+	Fail bool
+}
+
+// errLowQuality is returned when a score of the output is below its threshold.
+var errLowQuality = errors.New("quality is below the threshold")
+
+// measureQuality compares the output of t to the source file and
+// records the scores into e. Scores below the thresholds are warnings
+// or errors as configured by conf.Quality.
+//
+// The source is compared without subtitles, so hardsub outputs
+// score a bit lower than their video quality. The other stages of f
+// which change the frames, e.g. the crop, are applied to the source.
+func measureQuality(file string, t *ffmpeg.Transcoder, f ffmpeg.Filter, conf Config, e *report.Entry) error {
+	if len(conf.Quality.Metrics) == 0 {
+		return nil
+	}
+	if useBumpers(conf) {
+		// The frames of the output are not aligned with the source
+		msg := "quality was not measured, the output is joined with bumpers"
+		log.Print(msg)
+		e.Warnings = append(e.Warnings, msg)
+		return nil
+	}
+	log.Printf("measuring the quality of %s", e.Output)
+	scores, err := measure(file, t, f, conf, conf.Quality.Metrics)
+	if err != nil {
+		// The output is fine, e.g. ffmpeg may lack libvmaf
+		msg := fmt.Sprintf("was not able to measure quality: %s", err)
+		log.Print(msg)
+		e.Warnings = append(e.Warnings, msg)
+		return nil
+	}
+	e.Quality = &scores
+	log.Printf("quality: %s", formatScores(scores))
+
+	below := belowThreshold(scores, conf.Quality.Min)
+	if len(below) == 0 {
+		return nil
+	}
+	if conf.Quality.Fail {
+		return fmt.Errorf("%w: %s", errLowQuality, strings.Join(below, ", "))
+	}
+	for _, msg := range below {
+		log.Print(msg)
+		e.Warnings = append(e.Warnings, msg)
+	}
+	return nil
+}
+
+// measure compares the output of t to the part of the source file
+// which was encoded into it with the filter f.
+func measure(file string, t *ffmpeg.Transcoder, f ffmpeg.Filter, conf Config, metrics []ffmpeg.Metric) (ffmpeg.Scores, error) {
+	start, length := t.Window()
+	cmd := ffmpeg.MetricsCommand(conf.FFmpegPath, t.Output(), file, f, start, length, metrics)
+	if conf.Verbose {
+		fmt.Println(cmd)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return ffmpeg.Scores{}, err
+	}
+	return ffmpeg.ParseScores(string(out)), nil
+}
+
+// belowThreshold describes the measured scores which are below their minimum.
+func belowThreshold(s, min ffmpeg.Scores) []string {
+	var below []string
+	if s.VMAF != 0 && s.VMAF < min.VMAF {
+		below = append(below, fmt.Sprintf("VMAF %.2f is below %g", s.VMAF, min.VMAF))
+	}
+	if s.SSIM != 0 && s.SSIM < min.SSIM {
+		below = append(below, fmt.Sprintf("SSIM %.4f is below %g", s.SSIM, min.SSIM))
+	}
+	if s.PSNR != 0 && s.PSNR < min.PSNR {
+		below = append(below, fmt.Sprintf("PSNR %.2f dB is below %g dB", s.PSNR, min.PSNR))
+	}
+	return below
+}
+
+func formatScores(s ffmpeg.Scores) string {
+	var scores []string
+	if s.VMAF != 0 {
+		scores = append(scores, fmt.Sprintf("VMAF %.2f", s.VMAF))
+	}
+	if s.SSIM != 0 {
+		scores = append(scores, fmt.Sprintf("SSIM %.4f", s.SSIM))
+	}
+	if s.PSNR != 0 {
+		scores = append(scores, fmt.Sprintf("PSNR %.2f dB", s.PSNR))
+	}
+	if len(scores) == 0 {
+		return "no scores were reported"
+	}
+	return strings.Join(scores, ", ")
+}
@@ -0,0 +1,146 @@
+package burner
+
+import (
+	"errors"
+	"fmt"
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/jobqueue"
+	"github.com/shiroi-usagi/burner/remote"
+	"github.com/shiroi-usagi/burner/report"
+	"log"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// Worker is a machine which encodes jobs of the batch.
+type Worker struct {
+	Name      string
+	Transport remote.Transport
+	// WorkDir is the directory on the worker which holds the files of the jobs.
+	WorkDir string
+	// FFmpegPath is the ffmpeg executable on the worker.
+	FFmpegPath string
+}
+
+// burnRemoteJob encodes the job on the worker and measures
+// the wall time of the encoding into e.
+func burnRemoteJob(cmdOut *modifiableOutput, w Worker, job jobqueue.Job, conf Config, e *report.Entry) error {
+	start := time.Now()
+	defer func() {
+		e.WallTime = time.Since(start).Seconds()
+	}()
+	factory := factoryFor(conf.Mode)
+	if factory == nil {
+		return errors.New("unknown mode")
+	}
+	return burnRemote(cmdOut, w, job, factory, conf, e)
+}
+
+// burnRemote uploads the input to the worker, runs both passes
+// there and downloads the output into the output directory.
+//
+// The directory of the job is removed from the worker afterwards.
+func burnRemote(cmdOut *modifiableOutput, w Worker, job jobqueue.Job, factory factoryFunc, conf Config, e *report.Entry) error {
+	conf, f, _, err := plan(job.Input, conf, e)
+	if err != nil {
+		return err
+	}
+	// The samples of the search are encoded on this machine
+	conf, err = searchBitrate(cmdOut, job.Input, f, conf, e)
+	if err != nil {
+		return err
+	}
+
+	// The worker paths are always slash separated
+	dir := path.Join(w.WorkDir, fmt.Sprintf("job-%d", job.ID))
+	outDir := path.Join(dir, "out")
+	// The name of the input decides the name of the output
+	input := path.Join(dir, filepath.Base(job.Input))
+	defer func() {
+		if err := runRemote(w.Transport, "rm", "-rf", dir); err != nil {
+			log.Printf("was not able to clean up %s on %s: %s", dir, w.Name, err)
+		}
+	}()
+
+	log.Printf("uploading %s to %s", filepath.Base(job.Input), w.Name)
+	if err := w.Transport.Upload(job.Input, input); err != nil {
+		return err
+	}
+	// Avoid dealing with escaping characters in complex filter
+	if f.Subtitle != "" {
+		f.Subtitle = path.Join(dir, "tmp"+filepath.Ext(job.Input))
+		if err := runRemote(w.Transport, "ln", "-f", input, f.Subtitle); err != nil {
+			return err
+		}
+	}
+	if f.Watermark.Image != "" {
+		image := path.Join(dir, "watermark"+filepath.Ext(f.Watermark.Image))
+		if err := w.Transport.Upload(f.Watermark.Image, image); err != nil {
+			return err
+		}
+		f.Watermark.Image = image
+	}
+	for i, b := range f.Intro {
+		if f.Intro[i].Path, err = uploadBumper(w, dir, fmt.Sprintf("intro-%d", i), b); err != nil {
+			return err
+		}
+	}
+	for i, b := range f.Outro {
+		if f.Outro[i].Path, err = uploadBumper(w, dir, fmt.Sprintf("outro-%d", i), b); err != nil {
+			return err
+		}
+	}
+
+	conf, err = withTargetSize(conf, e, func(conf Config) error {
+		return withFontRetry(f, conf, e, func(f ffmpeg.Filter, conf Config) error {
+			t := newTranscoder(factory, w.FFmpegPath, input, outDir, f, conf)
+			// Keep the pass logs out of the downloaded directory
+			t.PassLogFile(path.Join(dir, "ffmpeg2pass"))
+			if err := runRemote(w.Transport, "mkdir", "-p", t.OutDir()); err != nil {
+				return err
+			}
+			wrap := func(cmd *exec.Cmd) *exec.Cmd {
+				return w.Transport.Command(cmd.Dir, cmd.Args...)
+			}
+			if err := runPasses(cmdOut, t, conf, e, wrap); err != nil {
+				return err
+			}
+
+			log.Printf("downloading %s from %s", filepath.Base(job.Input), w.Name)
+			if err := w.Transport.Download(outDir, conf.OutputDir); err != nil {
+				return err
+			}
+			local := factory(conf.FFmpegPath, job.Input, conf.OutputDir, conf.Video.Bitrate, f)
+			e.Output = local.Output()
+			e.OutputSize = outputSize(local)
+			// The renditions are converted on this machine
+			return writeRenditions(cmdOut, job.Input, local, f, conf, e)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	// The output is measured against the local source
+	return measureQuality(job.Input, factory(conf.FFmpegPath, job.Input, conf.OutputDir, conf.Video.Bitrate, f), f, conf, e)
+}
+
+// uploadBumper uploads the clip of the bumper into dir of the worker
+// and returns its path there.
+func uploadBumper(w Worker, dir, name string, b ffmpeg.Bumper) (string, error) {
+	p := path.Join(dir, name+filepath.Ext(b.Path))
+	if err := w.Transport.Upload(b.Path, p); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+// runRemote runs a helper command on the worker.
+func runRemote(t remote.Transport, args ...string) error {
+	out, err := t.Command("", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", args[0], err, out)
+	}
+	return nil
+}
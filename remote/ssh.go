@@ -0,0 +1,122 @@
+package remote
+
+import (
+	"fmt"
+	"github.com/shiroi-usagi/burner/commandline"
+	"net"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// SSH is a Transport which reaches the worker with the ssh client
+// of the system, the authentication is left to its configuration.
+//
+// The worker needs a POSIX shell, tar and ffmpeg.
+type SSH struct {
+	// Host is the destination in the [user@]host form.
+	Host string
+	Port int
+	// Executable of the ssh client, ssh by default.
+	Executable string
+}
+
+// ParseSSH parses a worker in the [user@]host[:port] form. IPv6 hosts
+// with a port are written in brackets, e.g. [fe80::1]:22, the brackets
+// are not kept in the host.
+func ParseSSH(s string) (SSH, error) {
+	var user string
+	host := s
+	if i := strings.LastIndex(s, "@"); i > -1 {
+		user, host = s[:i+1], s[i+1:]
+	}
+	// A host of more colons is an IPv6 address without a port
+	var t SSH
+	switch {
+	case strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]"):
+		host = host[1 : len(host)-1]
+	case strings.HasPrefix(host, "[") || strings.Count(host, ":") == 1:
+		h, port, err := net.SplitHostPort(host)
+		if err != nil {
+			return SSH{}, fmt.Errorf("invalid worker `%s`", s)
+		}
+		if t.Port, err = strconv.Atoi(port); err != nil {
+			return SSH{}, fmt.Errorf("invalid port in worker `%s`", s)
+		}
+		host = h
+	}
+	if host == "" {
+		return SSH{}, fmt.Errorf("invalid worker `%s`", s)
+	}
+	t.Host = user + host
+	return t, nil
+}
+
+func (t SSH) String() string {
+	return t.Host
+}
+
+func (t SSH) ssh(command string) *exec.Cmd {
+	executable := t.Executable
+	if executable == "" {
+		executable = "ssh"
+	}
+	var args []string
+	args = append(args, "-o", "BatchMode=yes") // Never ask for passwords.
+	if t.Port != 0 {
+		args = append(args, "-p", fmt.Sprint(t.Port))
+	}
+	args = append(args, t.Host, command)
+	return exec.Command(executable, args...)
+}
+
+// Command runs args on the worker.
+//
+// Without a terminal a killed ssh client does not signal the remote
+// process. ffmpeg still stops on the next write of its progress, as
+// the connection of its stdout is closed.
+func (t SSH) Command(dir string, args ...string) *exec.Cmd {
+	command := commandline.QuoteArgs(args)
+	if dir != "" {
+		command = fmt.Sprintf("cd %s && %s", commandline.Quote(dir), command)
+	}
+	return t.ssh(command)
+}
+
+func (t SSH) Upload(local, remote string) error {
+	f, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	cmd := t.ssh(fmt.Sprintf("mkdir -p %s && cat > %s", commandline.Quote(path.Dir(remote)), commandline.Quote(remote)))
+	cmd.Stdin = f
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("upload to %s: %w: %s", t.Host, err, out)
+	}
+	return nil
+}
+
+func (t SSH) Download(remote, local string) error {
+	cmd := t.ssh(fmt.Sprintf("tar -C %s -cf - .", commandline.Quote(remote)))
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if err := untar(stdout, local); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return err
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("download from %s: %w: %s", t.Host, err, stderr.String())
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package remote
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Transport runs commands on a worker and moves files between
+// the worker and the local machine.
+type Transport interface {
+	// Command returns a command which runs args in dir on the worker.
+	// The output of the returned command is the output of the remote one.
+	Command(dir string, args ...string) *exec.Cmd
+	// Upload copies the local file to the path on the worker.
+	Upload(local, remote string) error
+	// Download copies the content of the directory on the worker
+	// into the local directory.
+	Download(remote, local string) error
+}
+
+// Local is a Transport which runs the commands on this machine.
+// It stands in for a worker sharing the filesystem or in tests.
+type Local struct{}
+
+func (Local) Command(dir string, args ...string) *exec.Cmd {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = dir
+	return cmd
+}
+
+func (Local) Upload(local, remote string) error {
+	if err := os.MkdirAll(filepath.Dir(remote), 0755); err != nil {
+		return err
+	}
+	return copyFile(local, remote)
+}
+
+func (Local) Download(remote, local string) error {
+	return filepath.Walk(remote, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(remote, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(local, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		return copyFile(path, target)
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// untar extracts the tar stream into the directory.
+func untar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, h.Name)
+		// Avoid writing outside of the directory
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) && target != filepath.Clean(dir) {
+			return fmt.Errorf("invalid path in archive: %s", h.Name)
+		}
+		switch h.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.Create(target)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package remote
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSSH(t *testing.T) {
+	tests := []struct {
+		name    string
+		worker  string
+		want    SSH
+		wantErr bool
+	}{
+		{
+			name:   "host",
+			worker: "render1",
+			want:   SSH{Host: "render1"},
+		},
+		{
+			name:   "user and port",
+			worker: "burner@render1:2222",
+			want:   SSH{Host: "burner@render1", Port: 2222},
+		},
+		{
+			name:    "invalid port",
+			worker:  "render1:ssh",
+			wantErr: true,
+		},
+		{
+			name:    "missing host",
+			worker:  "burner@",
+			wantErr: true,
+		},
+		{
+			name:   "ipv6",
+			worker: "burner@::1",
+			want:   SSH{Host: "burner@::1"},
+		},
+		{
+			name:   "ipv6 and port",
+			worker: "[fe80::1]:22",
+			want:   SSH{Host: "fe80::1", Port: 22},
+		},
+		{
+			name:   "user, ipv6 and port",
+			worker: "burner@[2001:db8::7]:2222",
+			want:   SSH{Host: "burner@2001:db8::7", Port: 2222},
+		},
+		{
+			name:   "ipv6 in brackets",
+			worker: "[fe80::1]",
+			want:   SSH{Host: "fe80::1"},
+		},
+		{
+			name:    "unclosed bracket",
+			worker:  "[fe80::1:22",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSSH(tt.worker)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSSH() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSSH() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSSH_Command(t *testing.T) {
+	cmd := SSH{Host: "render1", Port: 2222}.Command("/tmp/job 1", "ffmpeg", "-i", "input.mkv")
+	want := []string{"ssh", "-o", "BatchMode=yes", "-p", "2222", "render1", "cd '/tmp/job 1' && ffmpeg -i input.mkv"}
+	if len(cmd.Args) != len(want) {
+		t.Fatalf("Command() = %q, want %q", cmd.Args, want)
+	}
+	for i := range want {
+		if cmd.Args[i] != want[i] {
+			t.Errorf("Command() = %q, want %q", cmd.Args, want)
+		}
+	}
+}
+
+func TestUntar(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "TestUntar")
+	if err != nil {
+		t.Fatal("creating temp dir:", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr bool
+	}{
+		{
+			name:  "nested file",
+			files: map[string]string{"ep/out.m3u8": "#EXTM3U"},
+		},
+		{
+			name:    "path outside of directory",
+			files:   map[string]string{"../evil": "x"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			for name, content := range tt.files {
+				_ = tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))})
+				_, _ = tw.Write([]byte(content))
+			}
+			_ = tw.Close()
+			err := untar(&buf, tmpDir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("untar() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			for name, content := range tt.files {
+				got, err := ioutil.ReadFile(filepath.Join(tmpDir, name))
+				if err != nil || string(got) != content {
+					t.Errorf("untar() %s = %q, %v, want %q", name, got, err, content)
+				}
+			}
+		})
+	}
+}
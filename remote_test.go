@@ -0,0 +1,68 @@
+package burner
+
+import (
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/jobqueue"
+	"github.com/shiroi-usagi/burner/remote"
+	"github.com/shiroi-usagi/burner/report"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fakeFFmpeg writes the arguments of the call into the output file.
+const fakeFFmpeg = `#!/bin/sh
+for last; do :; done
+echo "$@" > "$last"
+echo progress=end
+`
+
+func TestBurnRemote(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffmpeg is a shell script")
+	}
+	tmpDir, err := ioutil.TempDir("", "TestBurnRemote")
+	if err != nil {
+		t.Fatal("creating temp dir:", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	executable := filepath.Join(tmpDir, "ffmpeg")
+	if err := ioutil.WriteFile(executable, []byte(fakeFFmpeg), 0755); err != nil {
+		t.Fatal(err)
+	}
+	in, out, work := filepath.Join(tmpDir, "in"), filepath.Join(tmpDir, "out"), filepath.Join(tmpDir, "worker")
+	for _, dir := range []string{in, out, work} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	input := filepath.Join(in, "ep 01.mkv")
+	if err := ioutil.WriteFile(input, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := Worker{Name: "fake", Transport: remote.Local{}, WorkDir: work, FFmpegPath: executable}
+	conf := Config{Mode: ModeMP4, InputDir: in, OutputDir: out, Video: VideoConf{Height: 720, Bitrate: 1000 * ffmpeg.Kilobit}}
+	var e report.Entry
+	cmdOut := &modifiableOutput{Stdout: ioutil.Discard}
+	if err := burnRemoteJob(cmdOut, w, jobqueue.Job{ID: 7, Input: input}, conf, &e); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := filepath.Join(out, "ep 01.mp4"); e.Output != want {
+		t.Errorf("burnRemote() output = %s, want %s", e.Output, want)
+	}
+	b, err := ioutil.ReadFile(e.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.OutputSize != int64(len(b)) || e.OutputSize == 0 {
+		t.Errorf("burnRemote() output size = %d, want %d", e.OutputSize, len(b))
+	}
+	if _, err := os.Stat(filepath.Join(work, "job-7")); !os.IsNotExist(err) {
+		t.Errorf("burnRemote() should remove the job directory from the worker, %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package burner
+
+import (
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/ffprobe"
+)
+
+// The video of the transcode preset, which is copied when the input is
+// already encoded as it.
+const (
+	remuxCodec       = "h264"
+	remuxPixelFormat = "yuv420p"
+)
+
+// fitsTarget reports whether the probed video stream already meets the
+// target of v at the source bitrate, so it can be copied. Without the
+// upscaling smaller videos are kept as they are by the scale too.
+func fitsTarget(s ffprobe.Stream, source ffmpeg.Bitrate, v VideoConf) bool {
+	if s.CodecName != remuxCodec || s.PixFmt != remuxPixelFormat {
+		return false
+	}
+	if s.Height == 0 || s.Height > v.Height || v.Upscaling && s.Height != v.Height {
+		return false
+	}
+	return source > 0 && source <= v.Bitrate
+}
+
+// unfiltered reports whether the filter keeps the frames of the input
+// besides the scale.
+func unfiltered(f ffmpeg.Filter) bool {
+	return f.Crop.IsZero() &&
+		f.Deinterlacer == ffmpeg.DeinterlacerNone && !f.InverseTelecine &&
+		!f.ConstantRate &&
+		f.Tonemap.IsZero() &&
+		f.Watermark.Image == "" &&
+		f.Offset == 0
+}
+
+// copyVideo reports whether the probed video at the source bitrate is
+// copied into the output of the transcode mode instead of encoding it.
+// The outputs with a target size or quality are always encoded.
+func copyVideo(f ffmpeg.Filter, streams []ffprobe.Stream, source ffmpeg.Bitrate, conf Config) bool {
+	if !conf.Video.CopyCompatible || conf.Mode != ModeTranscode || conf.FFprobePath == "" {
+		return false
+	}
+	if conf.TargetSize > 0 || conf.Video.Search.TargetVMAF != 0 || !unfiltered(f) {
+		return false
+	}
+	video := ffprobe.OfType(streams, "video")
+	if len(video) == 0 {
+		return false
+	}
+	return fitsTarget(video[0], source, conf.Video)
+}
@@ -0,0 +1,36 @@
+package burner
+
+import (
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/ffprobe"
+	"testing"
+)
+
+func TestFitsTarget(t *testing.T) {
+	hd := ffprobe.Stream{CodecType: "video", CodecName: "h264", PixFmt: "yuv420p", Height: 720}
+	v := VideoConf{Height: 720, Bitrate: 2 * ffmpeg.Megabit}
+	tests := []struct {
+		name   string
+		s      ffprobe.Stream
+		source ffmpeg.Bitrate
+		v      VideoConf
+		want   bool
+	}{
+		{name: "fits", s: hd, source: ffmpeg.Megabit, v: v, want: true},
+		{name: "at the bitrate", s: hd, source: 2 * ffmpeg.Megabit, v: v, want: true},
+		{name: "above the bitrate", s: hd, source: 3 * ffmpeg.Megabit, v: v},
+		{name: "unknown bitrate", s: hd, v: v},
+		{name: "hevc", s: ffprobe.Stream{CodecName: "hevc", PixFmt: "yuv420p", Height: 720}, source: ffmpeg.Megabit, v: v},
+		{name: "10 bit", s: ffprobe.Stream{CodecName: "h264", PixFmt: "yuv420p10le", Height: 720}, source: ffmpeg.Megabit, v: v},
+		{name: "above the height", s: hd, source: ffmpeg.Megabit, v: VideoConf{Height: 480, Bitrate: 2 * ffmpeg.Megabit}},
+		{name: "below the height", s: hd, source: ffmpeg.Megabit, v: VideoConf{Height: 1080, Bitrate: 2 * ffmpeg.Megabit}, want: true},
+		{name: "below the height upscaled", s: hd, source: ffmpeg.Megabit, v: VideoConf{Height: 1080, Bitrate: 2 * ffmpeg.Megabit, Upscaling: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fitsTarget(tt.s, tt.source, tt.v); got != tt.want {
+				t.Errorf("fitsTarget() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
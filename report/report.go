@@ -0,0 +1,193 @@
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Entry is the outcome of a single input of the batch.
+//
+// Durations are in seconds.
+type Entry struct {
+	Input  string `json:"input"`
+	Output string `json:"output,omitempty"`
+	Mode   string `json:"mode"`
+
+	// Bitrate is the video bitrate of the encode, after the size heuristics.
+	Bitrate         string `json:"bitrate"`
+	BitrateModified bool   `json:"bitrate_modified"`
+	// SourceBitrate is the estimated video bitrate of the input.
+	SourceBitrate string `json:"source_bitrate,omitempty"`
+	// Crop is the w:h:x:y rectangle of the input in the output.
+	Crop string `json:"crop,omitempty"`
+	// Deinterlace is the deinterlacer of the output, or ivtc
+	// for the inverse telecine.
+	Deinterlace string `json:"deinterlace,omitempty"`
+	// FrameRate is the constant frame rate the output was converted to,
+	// or variable for the kept timestamps of a variable frame rate input.
+	FrameRate string `json:"frame_rate,omitempty"`
+	// Loudness are the values measured by the loudness normalization.
+	Loudness *ffmpeg.Loudness `json:"loudness,omitempty"`
+	// Tonemap is the algorithm which mapped the HDR input to SDR, gamut
+	// when only the BT.2020 primaries of a SDR input were converted.
+	Tonemap string `json:"tonemap,omitempty"`
+	// AudioCodec is the codec of the audio when it replaced the preset,
+	// copy when the audio of the input was kept.
+	AudioCodec string `json:"audio_codec,omitempty"`
+	// Softsubs are the languages of the subtitles muxed into the output.
+	Softsubs []string `json:"softsubs,omitempty"`
+	// Remuxed outputs have the video of the input copied without encoding.
+	Remuxed bool `json:"remuxed,omitempty"`
+	// BitrateProbes are the bitrates tried by the target quality search.
+	BitrateProbes []BitrateProbe `json:"bitrate_probes,omitempty"`
+
+	Duration       float64 `json:"duration"`
+	OutputSize     int64   `json:"output_size"`
+	WallTime       float64 `json:"wall_time"`
+	FirstPassTime  float64 `json:"first_pass_time"`
+	SecondPassTime float64 `json:"second_pass_time"`
+
+	// Warnings raised by the commandline handlers.
+	Warnings []string `json:"warnings,omitempty"`
+	// FFmpegWarnings are the de-duplicated lines of the ffmpeg output.
+	FFmpegWarnings []ffmpeg.Warning `json:"ffmpeg_warnings,omitempty"`
+	// Quality are the scores of the output against the source,
+	// nil when they were not measured.
+	Quality *ffmpeg.Scores `json:"quality,omitempty"`
+	// Degraded outputs were encoded with font errors ignored.
+	Degraded bool   `json:"degraded"`
+	Error    string `json:"error,omitempty"`
+}
+
+// BitrateProbe is the mean VMAF of the samples encoded at the bitrate.
+type BitrateProbe struct {
+	Bitrate string  `json:"bitrate"`
+	VMAF    float64 `json:"vmaf"`
+}
+
+// Failed reports whether the input could not be encoded.
+func (e Entry) Failed() bool {
+	return e.Error != ""
+}
+
+type Report struct {
+	Version  string    `json:"version"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Entries  []Entry   `json:"entries"`
+}
+
+// Failures counts the failed entries.
+func (r Report) Failures() int {
+	var n int
+	for _, e := range r.Entries {
+		if e.Failed() {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     float64          `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      float64         `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML with one test case per entry.
+func (r Report) WriteJUnit(w io.Writer) error {
+	elapsed := r.Finished.Sub(r.Started).Seconds()
+	suite := junitTestSuite{
+		Name:      "burner",
+		Tests:     len(r.Entries),
+		Failures:  r.Failures(),
+		Time:      elapsed,
+		Timestamp: r.Started.UTC().Format(time.RFC3339),
+	}
+	for _, e := range r.Entries {
+		c := junitTestCase{
+			Name:      filepath.Base(e.Input),
+			ClassName: e.Mode,
+			Time:      e.WallTime,
+		}
+		if e.Failed() {
+			c.Failure = &junitFailure{Message: e.Error, Text: e.Error}
+		}
+		if e.Remuxed {
+			c.SystemOut += fmt.Sprintln("remuxed, the video was copied without encoding")
+		}
+		for _, warning := range e.Warnings {
+			c.SystemOut += fmt.Sprintln(warning)
+		}
+		for _, w := range e.FFmpegWarnings {
+			c.SystemOut += fmt.Sprintf("%dx [%s] %s\n", w.Count, w.Kind, w.Message)
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	suites := junitTestSuites{
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Time:     elapsed,
+		Suites:   []junitTestSuite{suite},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteFile writes the report to name, the format is chosen by write.
+func WriteFile(name string, write func(w io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
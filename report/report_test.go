@@ -0,0 +1,62 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testReport = Report{
+	Version:  "v1.0.0",
+	Started:  time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+	Finished: time.Date(2022, 1, 1, 0, 10, 0, 0, time.UTC),
+	Entries: []Entry{
+		{
+			Input:    "/in/ok.mkv",
+			Mode:     "mp4",
+			Bitrate:  "1371k",
+			WallTime: 300,
+			Warnings: []string{"missing `Foo` font"},
+		},
+		{
+			Input:    "/in/fail.mkv",
+			Mode:     "mp4",
+			WallTime: 1,
+			Error:    "exit status 1",
+		},
+	},
+}
+
+func TestReport_WriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var got Report
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Entries) != 2 || got.Entries[1].Error != "exit status 1" || got.Entries[0].Bitrate != "1371k" {
+		t.Errorf("WriteJSON() = %s", buf.String())
+	}
+}
+
+func TestReport_WriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport.WriteJUnit(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		`<testsuites tests="2" failures="1" time="600">`,
+		`<testcase name="ok.mkv" classname="mp4" time="300">`,
+		"<system-out>missing `Foo` font",
+		`<failure message="exit status 1">exit status 1</failure>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("WriteJUnit() = %s, want to contain %s", got, want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package burner
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/shiroi-usagi/burner/commandline"
+	"github.com/shiroi-usagi/burner/jobqueue"
+	"github.com/shiroi-usagi/burner/report"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// scriptStep is the shell representation of a single input of the batch.
+type scriptStep struct {
+	name    string
+	input   string
+	outDir  string
+	link    [2]string
+	passes  []*exec.Cmd
+	cleanup []string
+}
+
+// isMakefile reports whether the script at path is a Makefile.
+func isMakefile(path string) bool {
+	base := filepath.Base(path)
+	return strings.EqualFold(base, "makefile") || filepath.Ext(base) == ".mk"
+}
+
+// emitScript writes the commands of the jobs to path as a POSIX shell
+// script or as a Makefile, depending on the name of the file.
+//
+// The jobs are planned on this machine, so the analyses of ffmpeg, e.g.
+// the crop detection and the loudness measurement, run before the script
+// is written and their results are part of the commands.
+func emitScript(path string, jobs []jobqueue.Job, conf Config) error {
+	var steps []scriptStep
+	for i, job := range jobs {
+		step, err := newScriptStep(i+1, job, conf)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(job.Input), err)
+		}
+		steps = append(steps, step)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if isMakefile(path) {
+		writeMakefile(w, steps)
+	} else {
+		writeShellScript(w, steps)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if isMakefile(path) {
+		return nil
+	}
+	return os.Chmod(path, 0755)
+}
+
+// newScriptStep plans the job. Every step has its own hard link and
+// pass log, so the steps of a Makefile can run in parallel.
+func newScriptStep(i int, job jobqueue.Job, conf Config) (scriptStep, error) {
+	conf = withSettings(conf, job.Settings)
+	factory := factoryFor(conf.Mode)
+	if factory == nil {
+		return scriptStep{}, fmt.Errorf("unknown mode `%s`", job.Settings.Mode)
+	}
+	var e report.Entry
+	conf, f, _, err := plan(job.Input, conf, &e)
+	if err != nil {
+		return scriptStep{}, err
+	}
+	name := fmt.Sprintf("burner-%03d", i)
+	link := filepath.Join(conf.OutputDir, name+filepath.Ext(job.Input))
+	if f.Subtitle != "" {
+		f.Subtitle = link
+	}
+	if conf.Mode == ModeFragmentedMP4 && len(f.Softsub.Streams) > 0 {
+		// The master playlist needs the peak bitrate of the encode
+		return scriptStep{}, errors.New("the WebVTT renditions of the subtitles can not be scripted")
+	}
+	t := newTranscoder(factory, "ffmpeg", job.Input, conf.OutputDir, f, conf)
+	t.PassLogFile(name)
+	passes := []*exec.Cmd{t.FirstPass(), t.SecondPass()}
+	if t.Remux() {
+		passes = []*exec.Cmd{t.SinglePass()}
+	}
+	return scriptStep{
+		name:   name,
+		input:  job.Input,
+		outDir: t.OutDir(),
+		link:   [2]string{job.Input, link},
+		passes: passes,
+		cleanup: []string{
+			link,
+			filepath.Join(t.OutDir(), name+"-0.log"),
+			filepath.Join(t.OutDir(), name+"-0.log.mbtree"),
+		},
+	}, nil
+}
+
+// ffmpegLine is the command line of cmd with the executable
+// replaced by the given variable reference.
+func ffmpegLine(cmd *exec.Cmd, executable string) string {
+	return fmt.Sprintf("(cd %s && %s %s)", commandline.Quote(cmd.Dir), executable, commandline.QuoteArgs(cmd.Args[1:]))
+}
+
+func writeShellScript(w io.Writer, steps []scriptStep) {
+	fmt.Fprintln(w, "#!/bin/sh")
+	fmt.Fprintf(w, "# Generated by burner %s, encodes %d file(s).\n", GetVersionInfo().Version, len(steps))
+	fmt.Fprintln(w, "# The ffmpeg executable can be set with the FFMPEG environment variable.")
+	fmt.Fprintln(w, "set -e")
+	fmt.Fprintln(w, `FFMPEG="${FFMPEG:-ffmpeg}"`)
+	for i, s := range steps {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "# [%03d/%03d] %s\n", i+1, len(steps), s.input)
+		for _, line := range stepLines(s, `"$FFMPEG"`) {
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+func writeMakefile(w io.Writer, steps []scriptStep) {
+	fmt.Fprintf(w, "# Generated by burner %s, encodes %d file(s).\n", GetVersionInfo().Version, len(steps))
+	fmt.Fprintln(w, "# The ffmpeg executable can be set with the FFMPEG variable.")
+	fmt.Fprintln(w, "FFMPEG ?= ffmpeg")
+	fmt.Fprintln(w)
+	var names []string
+	for _, s := range steps {
+		names = append(names, s.name)
+	}
+	fmt.Fprintf(w, ".PHONY: all %s\n", strings.Join(names, " "))
+	fmt.Fprintf(w, "all: %s\n", strings.Join(names, " "))
+	for _, s := range steps {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "# %s\n", s.input)
+		fmt.Fprintf(w, "%s:\n", s.name)
+		for _, line := range stepLines(s, "$(FFMPEG)") {
+			// make expands every $ of the recipe
+			line = strings.ReplaceAll(line, "$", "$$")
+			line = strings.ReplaceAll(line, "$$(FFMPEG)", "$(FFMPEG)")
+			fmt.Fprintf(w, "\t%s\n", line)
+		}
+	}
+}
+
+// stepLines are the shell commands of the step.
+func stepLines(s scriptStep, executable string) []string {
+	var rm []string
+	for _, p := range s.cleanup {
+		rm = append(rm, commandline.Quote(p))
+	}
+	lines := []string{
+		fmt.Sprintf("mkdir -p %s", commandline.Quote(s.outDir)),
+		// Avoid dealing with escaping characters in complex filter
+		fmt.Sprintf("ln -f %s %s", commandline.Quote(s.link[0]), commandline.Quote(s.link[1])),
+	}
+	for _, pass := range s.passes {
+		lines = append(lines, ffmpegLine(pass, executable))
+	}
+	return append(lines, fmt.Sprintf("rm -f %s", strings.Join(rm, " ")))
+}
@@ -0,0 +1,83 @@
+package burner
+
+import (
+	"bytes"
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/jobqueue"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestIsMakefile(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "/out/Makefile", want: true},
+		{path: "/out/makefile", want: true},
+		{path: "/out/batch.mk", want: true},
+		{path: "/out/batch.sh", want: false},
+		{path: "batch", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := isMakefile(tt.path); got != tt.want {
+				t.Errorf("isMakefile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteMakefile(t *testing.T) {
+	pass := exec.Command("ffmpeg", "-i", "/in/$ep.mkv", "out.mp4")
+	pass.Dir = "/out"
+	steps := []scriptStep{{
+		name:    "burner-001",
+		input:   "/in/$ep.mkv",
+		outDir:  "/out",
+		link:    [2]string{"/in/$ep.mkv", "/out/burner-001.mkv"},
+		passes:  []*exec.Cmd{pass, pass},
+		cleanup: []string{"/out/burner-001.mkv"},
+	}}
+	var buf bytes.Buffer
+	writeMakefile(&buf, steps)
+	got := buf.String()
+	for _, want := range []string{
+		"all: burner-001\n",
+		"burner-001:\n",
+		"\t(cd /out && $(FFMPEG) -i '/in/$$ep.mkv' out.mp4)\n",
+		"\trm -f /out/burner-001.mkv\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("writeMakefile() = %s, want to contain %q", got, want)
+		}
+	}
+}
+
+func TestNewScriptStep_Renditions(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "TestNewScriptStep_Renditions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	fakeFFmpeg, fakeFFprobe, _ := fakeTools(t, tmpDir)
+
+	conf := Config{
+		Mode:        ModeFragmentedMP4,
+		FFmpegPath:  fakeFFmpeg,
+		FFprobePath: fakeFFprobe,
+		OutputDir:   "/out",
+		Video:       VideoConf{Height: 720, Bitrate: 1371 * ffmpeg.Kilobit, KeepBitrate: true},
+		Subtitles:   SubtitlesSoft,
+	}
+	if _, err := newScriptStep(1, jobqueue.Job{Input: "/in/ep01.mkv"}, conf); err == nil {
+		t.Error("newScriptStep() did not fail on the subtitle renditions")
+	}
+	conf.Mode = ModeMP4
+	if _, err := newScriptStep(1, jobqueue.Job{Input: "/in/ep01.mkv"}, conf); err != nil {
+		t.Errorf("newScriptStep() = %v, want the mov_text subtitles to be scripted", err)
+	}
+}
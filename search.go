@@ -0,0 +1,126 @@
+package burner
+
+import (
+	"fmt"
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/report"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SearchConf enables searching the lowest video bitrate of each file
+// which meets a target quality. The bitrate of VideoConf is the highest
+// bitrate of the search.
+type SearchConf struct {
+	// TargetVMAF is the lowest accepted mean VMAF of the samples, zero
+	// disables the search.
+	TargetVMAF float64
+	// MinBitrate is the lowest bitrate of the search.
+	MinBitrate ffmpeg.Bitrate
+	// Samples is the number of windows encoded at every probed bitrate,
+	// they are spread evenly over the input.
+	Samples int
+	// SampleDuration is the length of a window.
+	SampleDuration time.Duration
+	// Steps is the highest number of probed bitrates.
+	Steps int
+}
+
+// searchPrecision stops the search when the bitrate is known
+// within this ratio of the upper bound.
+const searchPrecision = 0.05
+
+// searchBitrate encodes sample windows of the file at several bitrates
+// and returns the configuration with the lowest bitrate which meets the
+// target VMAF. The probes are recorded into e.
+//
+// The samples are encoded without the subtitle, so the score only
+// reflects the quality of the video.
+func searchBitrate(cmdOut *modifiableOutput, file string, f ffmpeg.Filter, conf Config, e *report.Entry) (Config, error) {
+	s := conf.Video.Search
+	if s.TargetVMAF == 0 || e.Duration == 0 {
+		return conf, nil
+	}
+	windows := sampleWindows(e.Duration, s.Samples, s.SampleDuration)
+	if len(windows) == 0 {
+		return conf, nil
+	}
+
+	dir := filepath.Join(conf.OutputDir, strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))+".search")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return conf, err
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	// The samples are compared to the source, so only the encode is measured
+	f.Subtitle = ""
+	f.Watermark = ffmpeg.Watermark{}
+	probe := func(bitrate ffmpeg.Bitrate) (float64, error) {
+		var total float64
+		for i, w := range windows {
+			name := fmt.Sprintf("sample-%03d", i)
+			t := ffmpeg.NewVideoChunkTranscoder(conf.FFmpegPath, file, name+".mp4", dir, bitrate, f, w.start, w.length)
+			t.PassLogFile(name)
+			if err := runPasses(cmdOut, t, conf, &report.Entry{}, nil); err != nil {
+				return 0, err
+			}
+			scores, err := measure(file, t, f, conf, []ffmpeg.Metric{ffmpeg.MetricVMAF})
+			if err != nil {
+				return 0, fmt.Errorf("was not able to measure quality: %w", err)
+			}
+			total += scores.VMAF
+		}
+		vmaf := total / float64(len(windows))
+		log.Printf("%s scores VMAF %.2f", bitrate, vmaf)
+		e.BitrateProbes = append(e.BitrateProbes, report.BitrateProbe{Bitrate: bitrate.String(), VMAF: vmaf})
+		return vmaf, nil
+	}
+
+	lo, hi := s.MinBitrate, conf.Video.Bitrate
+	if lo <= 0 || lo >= hi {
+		return conf, nil
+	}
+	log.Printf("searching bitrate between %s and %s for VMAF %g", s.MinBitrate, conf.Video.Bitrate, s.TargetVMAF)
+	// hi is accepted without probing, it is the bitrate without the search
+	best := hi
+	for step := 0; step < s.Steps && float64(hi-lo) > searchPrecision*float64(best); step++ {
+		mid := ((lo + hi) / 2).Truncate(ffmpeg.Kilobit)
+		vmaf, err := probe(mid)
+		if err != nil {
+			return conf, err
+		}
+		if vmaf >= s.TargetVMAF {
+			best, hi = mid, mid
+		} else {
+			lo = mid
+		}
+	}
+	conf.Video.Bitrate = best
+	log.Printf("bitrate was set to %s", conf.Video.Bitrate)
+	e.Bitrate = conf.Video.Bitrate.String()
+	return conf, nil
+}
+
+// sampleWindows spreads n windows of the given length evenly over the
+// input, the input is one window when it is too short.
+func sampleWindows(duration float64, n int, length time.Duration) []chunk {
+	if n < 1 || length <= 0 {
+		return nil
+	}
+	d := seconds(duration)
+	if d <= length*time.Duration(n) {
+		return []chunk{{start: 0, length: d}}
+	}
+	gap := (d - length*time.Duration(n)) / time.Duration(n+1)
+	var windows []chunk
+	for i := 0; i < n; i++ {
+		start := gap*time.Duration(i+1) + length*time.Duration(i)
+		windows = append(windows, chunk{start: start, length: length})
+	}
+	return windows
+}
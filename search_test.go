@@ -0,0 +1,48 @@
+package burner
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSampleWindows(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration float64
+		n        int
+		length   time.Duration
+		want     []chunk
+	}{
+		{
+			name:     "spread",
+			duration: 100,
+			n:        3,
+			length:   10 * time.Second,
+			want: []chunk{
+				{start: 17500 * time.Millisecond, length: 10 * time.Second},
+				{start: 45 * time.Second, length: 10 * time.Second},
+				{start: 72500 * time.Millisecond, length: 10 * time.Second},
+			},
+		},
+		{
+			name:     "short input",
+			duration: 25,
+			n:        3,
+			length:   10 * time.Second,
+			want:     []chunk{{start: 0, length: 25 * time.Second}},
+		},
+		{
+			name:     "no samples",
+			duration: 100,
+			length:   10 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sampleWindows(tt.duration, tt.n, tt.length); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sampleWindows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package burner
+
+import (
+	"fmt"
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/report"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// containerOverhead is the share of the output taken by the container.
+	containerOverhead = 0.02
+	// sizeAttempts is the number of encodes of a file to meet the target size.
+	sizeAttempts = 3
+	// sizeMargin lowers the corrected bitrate of an overshooting output further.
+	sizeMargin = 0.02
+)
+
+// sizeUnits are the suffixes of ParseSize, the longer suffixes are
+// first.
+var sizeUnits = []struct {
+	suffix string
+	unit   int64
+}{
+	{"Ki", 1 << 10},
+	{"Mi", 1 << 20},
+	{"Gi", 1 << 30},
+	{"k", 1000},
+	{"K", 1000},
+	{"M", 1000 * 1000},
+	{"G", 1000 * 1000 * 1000},
+}
+
+// ParseSize parses a file size in bytes with an optional suffix and B,
+// e.g. 350M or 350MiB. Like the bitrates, the k, M and G suffixes are
+// powers of 1000, Ki, Mi and Gi are powers of 1024.
+func ParseSize(size string) (int64, error) {
+	s := strings.TrimSuffix(strings.TrimSpace(size), "B")
+	var unit int64 = 1
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSuffix(s, u.suffix)
+			unit = u.unit
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n <= 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+		return 0, fmt.Errorf("invalid size `%s`, expected e.g. 367001600, 350M or 350Mi", size)
+	}
+	b := math.Round(n * float64(unit))
+	if b < 1 || b > math.MaxInt64/2 {
+		return 0, fmt.Errorf("size `%s` is out of range", size)
+	}
+	return int64(b), nil
+}
+
+// targetBitrate is the video bitrate of an output of the given size and
+// duration, it is zero when the size does not even hold the audio.
+func targetBitrate(size int64, duration float64, audio ffmpeg.Bitrate) ffmpeg.Bitrate {
+	bitrate := ffmpeg.Bitrate(float64(size)*(1-containerOverhead)*8/duration) - audio
+	if bitrate < ffmpeg.Kilobit {
+		return 0
+	}
+	return bitrate.Truncate(ffmpeg.Kilobit)
+}
+
+// withTargetSize runs encode until the output fits conf.TargetSize. An
+// overshooting output is encoded again with the bitrate lowered by the
+// overshoot. The returned configuration holds the bitrate of the output.
+func withTargetSize(conf Config, e *report.Entry, encode func(conf Config) error) (Config, error) {
+	for attempt := 1; ; attempt++ {
+		if err := encode(conf); err != nil {
+			return conf, err
+		}
+		if conf.TargetSize == 0 || e.OutputSize <= conf.TargetSize {
+			return conf, nil
+		}
+		if attempt == sizeAttempts {
+			return conf, fmt.Errorf("output is %d bytes, above the target size of %d bytes", e.OutputSize, conf.TargetSize)
+		}
+		total := float64(conf.Video.Bitrate + conf.Audio.Bitrate)
+		bitrate := ffmpeg.Bitrate(total*float64(conf.TargetSize)/float64(e.OutputSize)*(1-sizeMargin)) - conf.Audio.Bitrate
+		bitrate = bitrate.Truncate(ffmpeg.Kilobit)
+		if bitrate <= 0 {
+			return conf, fmt.Errorf("output is %d bytes, above the target size of %d bytes", e.OutputSize, conf.TargetSize)
+		}
+		msg := fmt.Sprintf("output overshot the target size by %d bytes, encoding again at %s",
+			e.OutputSize-conf.TargetSize, bitrate)
+		log.Print(msg)
+		e.Warnings = append(e.Warnings, msg)
+		conf.Video.Bitrate = bitrate
+		e.Bitrate = conf.Video.Bitrate.String()
+		e.BitrateModified = true
+	}
+}
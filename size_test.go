@@ -0,0 +1,126 @@
+package burner
+
+import (
+	"errors"
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/report"
+	"reflect"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size    string
+		want    int64
+		wantErr bool
+	}{
+		{size: "1024", want: 1024},
+		{size: "2k", want: 2000},
+		{size: "2Ki", want: 2048},
+		{size: "350M", want: 350 * 1000 * 1000},
+		{size: "350MB", want: 350 * 1000 * 1000},
+		{size: "350Mi", want: 350 * 1024 * 1024},
+		{size: "350MiB", want: 350 * 1024 * 1024},
+		{size: "1.5G", want: 1500 * 1000 * 1000},
+		{size: "1.5Gi", want: 1536 * 1024 * 1024},
+		{size: "M", wantErr: true},
+		{size: "-1M", wantErr: true},
+		{size: "1e300G", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.size, func(t *testing.T) {
+			got, err := ParseSize(tt.size)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTargetBitrate(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int64
+		duration float64
+		want     ffmpeg.Bitrate
+	}{
+		{name: "episode", size: 350 * 1024 * 1024, duration: 1440, want: 1870 * ffmpeg.Kilobit},
+		{name: "too small", size: 1024 * 1024, duration: 1440, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := targetBitrate(tt.size, tt.duration, 128*ffmpeg.Kilobit); got != tt.want {
+				t.Errorf("targetBitrate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithTargetSize(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      int64
+		sizes       []int64
+		wantBitrate []string
+		wantErr     bool
+	}{
+		{
+			name:        "disabled",
+			sizes:       []int64{2000},
+			wantBitrate: []string{"1M"},
+		},
+		{
+			name:        "fits",
+			target:      1000,
+			sizes:       []int64{1000},
+			wantBitrate: []string{"1M"},
+		},
+		{
+			name:        "overshoot",
+			target:      1000,
+			sizes:       []int64{1250, 1000},
+			wantBitrate: []string{"1M", "756k"},
+		},
+		{
+			name:        "keeps overshooting",
+			target:      1000,
+			sizes:       []int64{1250, 1100, 1050},
+			wantBitrate: []string{"1M", "756k", "659k"},
+			wantErr:     true,
+		},
+		{
+			name:        "failed encode",
+			target:      1000,
+			wantBitrate: []string{"1M"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e report.Entry
+			var bitrates []string
+			conf := Config{
+				TargetSize: tt.target,
+				Video:      VideoConf{Bitrate: 1000 * ffmpeg.Kilobit},
+				Audio:      AudioConf{Bitrate: 128 * ffmpeg.Kilobit},
+			}
+			_, err := withTargetSize(conf, &e, func(conf Config) error {
+				bitrates = append(bitrates, conf.Video.Bitrate.String())
+				if len(bitrates) > len(tt.sizes) {
+					return errors.New("encode failed")
+				}
+				e.OutputSize = tt.sizes[len(bitrates)-1]
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("withTargetSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(bitrates, tt.wantBitrate) {
+				t.Errorf("withTargetSize() bitrates = %v, want %v", bitrates, tt.wantBitrate)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package burner
+
+import (
+	"fmt"
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/ffprobe"
+	"github.com/shiroi-usagi/burner/report"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SubtitleMode decides how the subtitle of the hardsub modes is shown.
+//
+// The zero value burns the subtitle.
+type SubtitleMode string
+
+const (
+	// SubtitlesBurn renders the subtitle on the frames.
+	SubtitlesBurn SubtitleMode = "burn"
+	// SubtitlesSoft muxes the text subtitles of the input, they can be
+	// toggled by the viewers.
+	SubtitlesSoft SubtitleMode = "soft"
+	// SubtitlesBoth burns the subtitle and muxes the text subtitles.
+	SubtitlesBoth SubtitleMode = "both"
+	// SubtitlesNone neither burns nor muxes the subtitles.
+	SubtitlesNone SubtitleMode = "none"
+)
+
+// ParseSubtitleMode parses burn, soft, both or none.
+func ParseSubtitleMode(s string) (SubtitleMode, error) {
+	switch m := SubtitleMode(strings.ToLower(strings.TrimSpace(s))); m {
+	case SubtitlesBurn, SubtitlesSoft, SubtitlesBoth, SubtitlesNone:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid value `%s`, expected burn, soft, both or none", s)
+}
+
+// Burns reports whether the subtitle is rendered on the frames.
+func (m SubtitleMode) Burns() bool {
+	return m == "" || m == SubtitlesBurn || m == SubtitlesBoth
+}
+
+// Muxes reports whether the text subtitles are muxed into the outputs.
+func (m SubtitleMode) Muxes() bool {
+	return m == SubtitlesSoft || m == SubtitlesBoth
+}
+
+// useSoftsub reports whether the text subtitles are muxed into the
+// outputs of conf, the mp4 and fragmented mp4 modes hold them.
+func useSoftsub(conf Config) bool {
+	return (conf.Mode == ModeMP4 || conf.Mode == ModeFragmentedMP4) && conf.Subtitles.Muxes()
+}
+
+// textSubtitles are the subtitle streams which can be muxed, bitmap
+// subtitles are skipped with a warning.
+func textSubtitles(streams []ffprobe.Stream, e *report.Entry) []ffmpeg.SubtitleStream {
+	var subtitles []ffmpeg.SubtitleStream
+	for _, s := range ffprobe.OfType(streams, "subtitle") {
+		if !ffmpeg.IsTextSubtitle(s.CodecName) {
+			msg := fmt.Sprintf("subtitle #%d is %s, only text subtitles are muxed", s.Index, s.CodecName)
+			log.Print(msg)
+			e.Warnings = append(e.Warnings, msg)
+			continue
+		}
+		subtitles = append(subtitles, ffmpeg.SubtitleStream{
+			Map:      fmt.Sprintf("0:%d", s.Index),
+			Language: s.Tags.Language,
+			Title:    s.Tags.Title,
+			Default:  s.Disposition.Default == 1,
+		})
+	}
+	return subtitles
+}
+
+// planSoftsub sets the probed text subtitles which are muxed into the
+// output with the audio stream selected by ffmpeg. The subtitles are
+// probed, so they need ffprobe.
+func planSoftsub(f ffmpeg.Filter, streams []ffprobe.Stream, conf Config, e *report.Entry) ffmpeg.Filter {
+	warn := func(msg string) {
+		log.Print(msg)
+		e.Warnings = append(e.Warnings, msg)
+	}
+	if useBumpers(conf) {
+		// The subtitles are not aligned with the joined output
+		warn("subtitles are not muxed, the output is joined with bumpers")
+		return f
+	}
+	if conf.FFprobePath == "" {
+		warn("subtitles are not muxed, the streams can not be probed without ffprobe")
+		return f
+	}
+	s := ffmpeg.Softsub{Streams: textSubtitles(streams, e)}
+	if len(s.Streams) == 0 {
+		return f
+	}
+	if a, ok := defaultAudio(streams); ok {
+		s.Audio = fmt.Sprintf("0:%d", a.Index)
+	}
+	for _, stream := range s.Streams {
+		language := stream.Language
+		if language == "" {
+			language = "und"
+		}
+		e.Softsubs = append(e.Softsubs, language)
+	}
+	log.Printf("subtitles were set to %s", strings.Join(e.Softsubs, ", "))
+	f.Softsub = s
+	return f
+}
+
+// writeRenditions converts the muxed subtitles of the HLS output of t to
+// WebVTT renditions, which are referenced by the master playlist written
+// next to the playlist of the output. e.Output is set to the master.
+//
+// The bandwidth of the master is the peak bitrate of the segments.
+func writeRenditions(cmdOut *modifiableOutput, file string, t *ffmpeg.Transcoder, f ffmpeg.Filter, conf Config, e *report.Entry) error {
+	if conf.Mode != ModeFragmentedMP4 || len(f.Softsub.Streams) == 0 {
+		return nil
+	}
+	renditions := f.Softsub.Renditions()
+	for _, r := range renditions {
+		cmd := ffmpeg.SubtitleSegments(conf.FFmpegPath, file, t.Output(), t.OutDir(), r, ffmpeg.HlsSegmentDuration)
+		if err := runCommand(cmdOut, cmd, ffmpeg.NewWarningCollector(), conf); err != nil {
+			return fmt.Errorf("was not able to convert subtitle %s: %w", r.Map, err)
+		}
+		if err := removeScratch(t.OutDir(), r.Scratch()); err != nil {
+			return err
+		}
+	}
+	bandwidth, err := ffmpeg.PeakBitrate(t.Output())
+	if err != nil {
+		return fmt.Errorf("was not able to measure the peak bitrate: %w", err)
+	}
+
+	master := filepath.Join(t.OutDir(), "master.m3u8")
+	w, err := os.Create(master)
+	if err != nil {
+		return err
+	}
+	if err := ffmpeg.WriteMasterPlaylist(w, filepath.Base(t.Output()), bandwidth, renditions); err != nil {
+		_ = w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	e.Output = master
+	e.OutputSize = outputSize(t)
+	return nil
+}
+
+// removeScratch removes the files of dir matching the glob patterns.
+func removeScratch(dir string, patterns []string) error {
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return err
+		}
+		for _, m := range matches {
+			if err := os.Remove(m); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
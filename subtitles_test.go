@@ -0,0 +1,54 @@
+package burner
+
+import (
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/ffprobe"
+	"github.com/shiroi-usagi/burner/report"
+	"reflect"
+	"testing"
+)
+
+func TestSubtitleMode(t *testing.T) {
+	tests := []struct {
+		mode      SubtitleMode
+		wantBurns bool
+		wantMuxes bool
+	}{
+		{mode: "", wantBurns: true},
+		{mode: SubtitlesBurn, wantBurns: true},
+		{mode: SubtitlesSoft, wantMuxes: true},
+		{mode: SubtitlesBoth, wantBurns: true, wantMuxes: true},
+		{mode: SubtitlesNone},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			if got := tt.mode.Burns(); got != tt.wantBurns {
+				t.Errorf("Burns() = %v, want %v", got, tt.wantBurns)
+			}
+			if got := tt.mode.Muxes(); got != tt.wantMuxes {
+				t.Errorf("Muxes() = %v, want %v", got, tt.wantMuxes)
+			}
+		})
+	}
+}
+
+func TestTextSubtitles(t *testing.T) {
+	streams := []ffprobe.Stream{
+		{Index: 0, CodecType: "video", CodecName: "h264"},
+		{Index: 1, CodecType: "audio", CodecName: "aac"},
+		{Index: 2, CodecType: "subtitle", CodecName: "ass", Tags: ffprobe.Tags{Language: "eng", Title: "Full"}, Disposition: ffprobe.Disposition{Default: 1}},
+		{Index: 3, CodecType: "subtitle", CodecName: "hdmv_pgs_subtitle", Tags: ffprobe.Tags{Language: "jpn"}},
+		{Index: 4, CodecType: "subtitle", CodecName: "subrip"},
+	}
+	want := []ffmpeg.SubtitleStream{
+		{Map: "0:2", Language: "eng", Title: "Full", Default: true},
+		{Map: "0:4"},
+	}
+	var e report.Entry
+	if got := textSubtitles(streams, &e); !reflect.DeepEqual(got, want) {
+		t.Errorf("textSubtitles() = %v, want %v", got, want)
+	}
+	if len(e.Warnings) != 1 {
+		t.Errorf("Warnings = %v, want the bitmap subtitle", e.Warnings)
+	}
+}
@@ -0,0 +1,34 @@
+package burner
+
+import (
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"github.com/shiroi-usagi/burner/ffprobe"
+)
+
+// detectColor reports whether the video is HDR or SDR with the wide
+// gamut of BT.2020, from the color properties of the first probed video
+// stream.
+func detectColor(streams []ffprobe.Stream) (hdr, wide bool) {
+	video := ffprobe.OfType(streams, "video")
+	if len(video) == 0 {
+		return false, false
+	}
+	return ffmpeg.IsHDR(video[0].ColorTransfer), ffmpeg.IsWideGamut(video[0].ColorTransfer, video[0].ColorPrimaries)
+}
+
+// tonemapFor decides the tone mapping of the filter from the settings
+// and the detection, which is only used by the auto setting. The auto
+// setting only converts the primaries of the wide gamut SDR inputs.
+func tonemapFor(v VideoConf, hdr, wide bool) ffmpeg.Tonemap {
+	if v.Tonemap == SwitchAuto && !hdr && wide {
+		return ffmpeg.Tonemap{Gamut: true}
+	}
+	if v.Tonemap != SwitchOn && (v.Tonemap != SwitchAuto || !hdr) {
+		return ffmpeg.Tonemap{}
+	}
+	algorithm := v.TonemapAlgorithm
+	if algorithm == "" {
+		algorithm = ffmpeg.TonemapHable
+	}
+	return ffmpeg.Tonemap{Algorithm: algorithm, Peak: v.TonemapPeak}
+}
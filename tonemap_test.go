@@ -0,0 +1,34 @@
+package burner
+
+import (
+	"github.com/shiroi-usagi/burner/ffmpeg"
+	"testing"
+)
+
+func TestTonemapFor(t *testing.T) {
+	tests := []struct {
+		name string
+		v    VideoConf
+		hdr  bool
+		wide bool
+		want ffmpeg.Tonemap
+	}{
+		{name: "off", v: VideoConf{}, hdr: true},
+		{name: "auto sdr", v: VideoConf{Tonemap: SwitchAuto}},
+		{name: "auto hdr", v: VideoConf{Tonemap: SwitchAuto}, hdr: true, want: ffmpeg.Tonemap{Algorithm: ffmpeg.TonemapHable}},
+		{name: "auto wide gamut", v: VideoConf{Tonemap: SwitchAuto}, wide: true, want: ffmpeg.Tonemap{Gamut: true}},
+		{name: "off wide gamut", v: VideoConf{}, wide: true},
+		{
+			name: "on",
+			v:    VideoConf{Tonemap: SwitchOn, TonemapAlgorithm: ffmpeg.TonemapMobius, TonemapPeak: 10},
+			want: ffmpeg.Tonemap{Algorithm: ffmpeg.TonemapMobius, Peak: 10},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tonemapFor(tt.v, tt.hdr, tt.wide); got != tt.want {
+				t.Errorf("tonemapFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}